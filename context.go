@@ -0,0 +1,36 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package worker
+
+import "context"
+
+type contextKey int
+
+const (
+	workerIDKey contextKey = iota
+	attemptKey
+)
+
+// withStartValues returns a copy of ctx holding the id of
+// a worker and the number of the attempt to start it.
+func withStartValues(ctx context.Context, id string, attempt int) context.Context {
+	ctx = context.WithValue(ctx, workerIDKey, id)
+	return context.WithValue(ctx, attemptKey, attempt)
+}
+
+// WorkerIDFromContext returns the id of the worker being started
+// with the given context by a Runner. It reports false if the
+// context wasn't created by a Runner.
+func WorkerIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(workerIDKey).(string)
+	return id, ok
+}
+
+// AttemptFromContext returns the number of the attempt to start
+// a worker, counting from 1, for the given context created by a
+// Runner. It reports false if the context wasn't created by a Runner.
+func AttemptFromContext(ctx context.Context) (int, bool) {
+	attempt, ok := ctx.Value(attemptKey).(int)
+	return attempt, ok
+}
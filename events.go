@@ -0,0 +1,150 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package worker
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// EventKind describes what happened to a worker in a Runner.
+type EventKind string
+
+const (
+	// EventStarted is sent when a worker has been started.
+	EventStarted EventKind = "started"
+
+	// EventStopped is sent when a worker, or its start function,
+	// has exited without error, including when it was asked to stop.
+	// It isn't sent when a pending start or restart is cancelled.
+	EventStopped EventKind = "stopped"
+
+	// EventError is sent when a worker has exited, or failed
+	// to start, with an error that is not fatal.
+	EventError EventKind = "error"
+
+	// EventFatal is sent when a worker has exited, or failed
+	// to start, with a fatal error.
+	EventFatal EventKind = "fatal"
+
+	// EventFailed is sent when a worker has failed too often
+	// and will not be restarted until it is reset.
+	EventFailed EventKind = "failed"
+
+	// EventExhausted is sent when a worker has used up all of
+	// its restarts and will not be restarted until it is reset.
+	EventExhausted EventKind = "exhausted"
+
+	// EventRestartScheduled is sent when a worker will be
+	// restarted after the event's Delay.
+	EventRestartScheduled EventKind = "restart-scheduled"
+
+	// EventRemoved is sent when a worker has been removed
+	// from the runner and will not be started again.
+	EventRemoved EventKind = "removed"
+)
+
+// Event describes a change in the lifecycle of a worker
+// managed by a Runner.
+type Event struct {
+	// Kind holds what happened to the worker.
+	Kind EventKind
+
+	// ID holds the id of the worker.
+	ID string
+
+	// Err holds the error the worker exited with,
+	// for EventError, EventFatal and EventFailed events, and
+	// for EventExhausted events if the worker exited with one.
+	Err error
+
+	// Delay holds the length of time before the worker
+	// is restarted, for EventRestartScheduled events.
+	Delay time.Duration
+
+	// Time holds the time, according to the runner's
+	// clock, that the event happened.
+	Time time.Time
+}
+
+// Subscription delivers the lifecycle events of the workers
+// in a Runner. Events are delivered without blocking the runner;
+// if the subscriber doesn't keep up, events are dropped.
+type Subscription struct {
+	runner  *Runner
+	events  chan Event
+	dropped int64
+}
+
+// Subscribe returns a new Subscription to the lifecycle events of the
+// runner's workers. At most size events will be buffered waiting to be
+// read; any more are dropped. The events channel is closed when the
+// subscription is cancelled with Unsubscribe or the runner finishes.
+func (runner *Runner) Subscribe(size int) *Subscription {
+	sub := &Subscription{
+		runner: runner,
+		events: make(chan Event, size),
+	}
+	runner.subsMu.Lock()
+	defer runner.subsMu.Unlock()
+	if runner.subsClosed {
+		close(sub.events)
+		return sub
+	}
+	if runner.subs == nil {
+		runner.subs = make(map[*Subscription]bool)
+	}
+	runner.subs[sub] = true
+	return sub
+}
+
+// Events returns the channel on which events are delivered.
+func (sub *Subscription) Events() <-chan Event {
+	return sub.events
+}
+
+// Dropped returns the number of events that could not be delivered
+// because the subscription's buffer was full.
+func (sub *Subscription) Dropped() int {
+	return int(atomic.LoadInt64(&sub.dropped))
+}
+
+// Unsubscribe cancels the subscription and closes its events
+// channel. It is safe to call more than once.
+func (sub *Subscription) Unsubscribe() {
+	runner := sub.runner
+	runner.subsMu.Lock()
+	defer runner.subsMu.Unlock()
+	if runner.subs[sub] {
+		delete(runner.subs, sub)
+		close(sub.events)
+	}
+}
+
+// publish sends an event to all the subscribers without blocking.
+// It should only be called from the run goroutine.
+func (runner *Runner) publish(event Event) {
+	event.Time = runner.params.Clock.Now().UTC()
+	runner.subsMu.Lock()
+	defer runner.subsMu.Unlock()
+	for sub := range runner.subs {
+		select {
+		case sub.events <- event:
+		default:
+			atomic.AddInt64(&sub.dropped, 1)
+		}
+	}
+}
+
+// closeSubscriptions closes the events channels of
+// all the subscribers.
+func (runner *Runner) closeSubscriptions() {
+	runner.subsMu.Lock()
+	defer runner.subsMu.Unlock()
+	for sub := range runner.subs {
+		close(sub.events)
+	}
+	runner.subs = nil
+	runner.subsClosed = true
+}
@@ -0,0 +1,201 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package worker_test
+
+import (
+	"time"
+
+	"github.com/juju/clock/testclock"
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/worker/v3"
+)
+
+type EventsSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&EventsSuite{})
+
+func (*EventsSuite) TestWorkerLifecycle(c *gc.C) {
+	// Event times are always in UTC.
+	t0 := time.Date(2022, 2, 3, 4, 5, 6, 0, time.FixedZone("UTC+1", 60*60))
+	clock := testclock.NewClock(t0)
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:      noneFatal,
+		RestartDelay: time.Millisecond,
+		Clock:        clock,
+	})
+	defer worker.Stop(runner)
+	sub := runner.Subscribe(10)
+	defer sub.Unsubscribe()
+
+	starter := newTestWorkerStarter()
+	err := runner.StartWorker("id", starter.start)
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, true)
+	assertEvent(c, sub, worker.Event{Kind: worker.EventStarted, ID: "id", Time: t0.UTC()})
+
+	dieErr := errors.New("boom")
+	starter.die <- dieErr
+	starter.assertStarted(c, false)
+	assertEvent(c, sub, worker.Event{Kind: worker.EventError, ID: "id", Err: dieErr, Time: t0.UTC()})
+	assertEvent(c, sub, worker.Event{Kind: worker.EventRestartScheduled, ID: "id", Delay: time.Millisecond, Time: t0.UTC()})
+
+	err = clock.WaitAdvance(time.Millisecond, longWait, 1)
+	c.Assert(err, jc.ErrorIsNil)
+	t1 := t0.Add(time.Millisecond).UTC()
+	starter.assertStarted(c, true)
+	assertEvent(c, sub, worker.Event{Kind: worker.EventStarted, ID: "id", Time: t1})
+
+	err = runner.StopWorker("id")
+	c.Assert(err, jc.ErrorIsNil)
+	assertEvent(c, sub, worker.Event{Kind: worker.EventStopped, ID: "id", Time: t1})
+	assertEvent(c, sub, worker.Event{Kind: worker.EventRemoved, ID: "id", Time: t1})
+}
+
+func (*EventsSuite) TestCancelledRestartIsNotStopped(c *gc.C) {
+	t0 := time.Date(2022, 2, 3, 4, 5, 6, 0, time.UTC)
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:      noneFatal,
+		RestartDelay: time.Minute,
+		Clock:        testclock.NewClock(t0),
+	})
+	defer worker.Stop(runner)
+	sub := runner.Subscribe(10)
+	defer sub.Unsubscribe()
+
+	starter := newTestWorkerStarter()
+	err := runner.StartWorker("id", starter.start)
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, true)
+	assertEvent(c, sub, worker.Event{Kind: worker.EventStarted, ID: "id", Time: t0})
+	dieErr := errors.New("boom")
+	starter.die <- dieErr
+	starter.assertStarted(c, false)
+	assertEvent(c, sub, worker.Event{Kind: worker.EventError, ID: "id", Err: dieErr, Time: t0})
+	assertEvent(c, sub, worker.Event{Kind: worker.EventRestartScheduled, ID: "id", Delay: time.Minute, Time: t0})
+
+	// Pausing the worker cancels its pending restart, and resuming
+	// it starts it again, but it was never running to be stopped.
+	err = runner.PauseWorker("id")
+	c.Assert(err, jc.ErrorIsNil)
+	err = runner.ResumeWorker("id")
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, true)
+	assertEvent(c, sub, worker.Event{Kind: worker.EventRestartScheduled, ID: "id", Time: t0})
+	assertEvent(c, sub, worker.Event{Kind: worker.EventStarted, ID: "id", Time: t0})
+}
+
+func (*EventsSuite) TestFatal(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:      allFatal,
+		RestartDelay: time.Millisecond,
+	})
+	sub := runner.Subscribe(10)
+
+	starter := newTestWorkerStarter()
+	starter.startErr = errors.New("cannot start")
+	err := runner.StartWorker("id", starter.start)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(runner.Wait(), gc.Equals, starter.startErr)
+
+	var kinds []worker.EventKind
+	for event := range sub.Events() {
+		kinds = append(kinds, event.Kind)
+		if event.Kind == worker.EventFatal {
+			c.Check(event.Err, gc.Equals, starter.startErr)
+		}
+	}
+	c.Assert(kinds, jc.DeepEquals, []worker.EventKind{worker.EventFatal, worker.EventRemoved})
+}
+
+func (*EventsSuite) TestExhausted(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:      noneFatal,
+		RestartDelay: time.Millisecond,
+	})
+	defer worker.Stop(runner)
+	sub := runner.Subscribe(10)
+	defer sub.Unsubscribe()
+
+	starter := newTestWorkerStarter()
+	starter.startErr = errors.New("cannot start")
+	err := runner.StartWorker("id", starter.start, worker.WithRestartPolicy(worker.RestartPolicy{
+		MaxRestarts: 1,
+	}))
+	c.Assert(err, jc.ErrorIsNil)
+
+	var kinds []worker.EventKind
+	for len(kinds) == 0 || kinds[len(kinds)-1] != worker.EventExhausted {
+		select {
+		case event := <-sub.Events():
+			kinds = append(kinds, event.Kind)
+			if event.Kind == worker.EventExhausted {
+				c.Check(event.Err, gc.Equals, starter.startErr)
+			}
+		case <-time.After(longWait):
+			c.Fatalf("timed out waiting for exhausted event")
+		}
+	}
+	c.Assert(kinds, jc.DeepEquals, []worker.EventKind{
+		worker.EventError,
+		worker.EventRestartScheduled,
+		worker.EventError,
+		worker.EventExhausted,
+	})
+}
+
+func (*EventsSuite) TestSlowSubscriberDoesNotBlock(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:      noneFatal,
+		RestartDelay: time.Millisecond,
+	})
+	defer worker.Stop(runner)
+	sub := runner.Subscribe(1)
+	defer sub.Unsubscribe()
+
+	starter := newTestWorkerStarter()
+	err := runner.StartWorker("id", starter.start)
+	c.Assert(err, jc.ErrorIsNil)
+	for i := 0; i < 3; i++ {
+		starter.assertStarted(c, true)
+		starter.die <- errors.New("boom")
+		starter.assertStarted(c, false)
+	}
+	starter.assertStarted(c, true)
+	c.Assert(sub.Dropped() > 0, jc.IsTrue)
+}
+
+func (*EventsSuite) TestUnsubscribe(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{})
+	defer worker.Stop(runner)
+	sub := runner.Subscribe(1)
+	sub.Unsubscribe()
+	sub.Unsubscribe()
+	_, ok := <-sub.Events()
+	c.Assert(ok, jc.IsFalse)
+}
+
+func (*EventsSuite) TestSubscribeWhenDead(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{})
+	c.Assert(worker.Stop(runner), jc.ErrorIsNil)
+	sub := runner.Subscribe(1)
+	_, ok := <-sub.Events()
+	c.Assert(ok, jc.IsFalse)
+	sub.Unsubscribe()
+}
+
+func assertEvent(c *gc.C, sub *worker.Subscription, expect worker.Event) {
+	select {
+	case event := <-sub.Events():
+		c.Assert(event, jc.DeepEquals, expect)
+		c.Assert(event.Time.Location(), gc.Equals, time.UTC)
+	case <-time.After(longWait):
+		c.Fatalf("timed out waiting for %s event", expect.Kind)
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package worker
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/juju/clock"
+	"github.com/juju/errors"
+)
+
+// ErrLeaseLost is returned by a singleton worker's attempt when
+// the worker is killed because its lease has been lost. The Runner
+// doesn't treat it as fatal; it claims the lease again and starts
+// a new worker when it gets it. A worker that returns ErrLeaseLost
+// itself is treated like one that returns any other error.
+var ErrLeaseLost = errors.New("lease lost")
+
+// Lease controls which of several processes may run a singleton
+// worker. A Runner claims the lease before starting the worker
+// and releases it when the worker has exited.
+type Lease interface {
+	// Claim blocks until the lease is held or the context is
+	// cancelled. It returns a channel that is closed if the lease
+	// is lost while it is held; the channel may be nil if the
+	// lease can't be lost.
+	Claim(ctx context.Context) (lost <-chan struct{}, err error)
+
+	// Release releases the lease, if it is held.
+	Release() error
+}
+
+// DefaultLeaseRetryDelay holds the default length of time that a
+// FileLease waits between attempts to lock its file.
+const DefaultLeaseRetryDelay = time.Second
+
+// FileLeaseParams holds the parameters for a NewFileLease call.
+type FileLeaseParams struct {
+	// Path holds the path of the file to lock. It is
+	// created if it doesn't exist.
+	Path string
+
+	// RetryDelay holds the length of time to wait between
+	// attempts to lock the file. If this is zero,
+	// DefaultLeaseRetryDelay will be used.
+	RetryDelay time.Duration
+
+	// Clock is used for timekeeping. If it's nil, clock.WallClock
+	// will be used.
+	Clock Clock
+}
+
+// FileLease is a Lease held by locking a file, so that a singleton
+// worker only runs in one process at a time on the local machine.
+// A FileLease is never lost once it has been claimed.
+type FileLease struct {
+	params FileLeaseParams
+
+	// mu guards the fields below it.
+	mu sync.Mutex
+
+	// file holds the locked file while the lease is held,
+	// and claiming holds whether a claim is in progress.
+	file     *os.File
+	claiming bool
+}
+
+// NewFileLease returns a new FileLease.
+func NewFileLease(p FileLeaseParams) *FileLease {
+	if p.RetryDelay == 0 {
+		p.RetryDelay = DefaultLeaseRetryDelay
+	}
+	if p.Clock == nil {
+		p.Clock = clock.WallClock
+	}
+	return &FileLease{params: p}
+}
+
+// Claim implements Lease.
+func (l *FileLease) Claim(ctx context.Context) (<-chan struct{}, error) {
+	l.mu.Lock()
+	if l.file != nil {
+		l.mu.Unlock()
+		return nil, errors.Errorf("lease %q already held", l.params.Path)
+	}
+	if l.claiming {
+		l.mu.Unlock()
+		return nil, errors.Errorf("lease %q already being claimed", l.params.Path)
+	}
+	l.claiming = true
+	l.mu.Unlock()
+
+	// The mutex isn't held while we wait for the lock,
+	// so that Release and other claims don't block.
+	f, err := l.lockFile(ctx)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.claiming = false
+	if err != nil {
+		return nil, err
+	}
+	l.file = f
+	return nil, nil
+}
+
+// lockFile opens the lease's file and waits until
+// it can be locked, or the context is cancelled.
+func (l *FileLease) lockFile(ctx context.Context) (*os.File, error) {
+	f, err := os.OpenFile(l.params.Path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for {
+		locked, err := tryLockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, errors.Annotatef(err, "cannot lock %q", l.params.Path)
+		}
+		if locked {
+			return f, nil
+		}
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, ctx.Err()
+		case <-l.params.Clock.After(l.params.RetryDelay):
+		}
+	}
+}
+
+// Release implements Lease.
+func (l *FileLease) Release() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	// Closing the file releases the lock.
+	err := l.file.Close()
+	l.file = nil
+	return errors.Trace(err)
+}
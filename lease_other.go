@@ -0,0 +1,19 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package worker
+
+import (
+	"os"
+
+	"github.com/juju/errors"
+)
+
+// tryLockFile always fails, as file locking
+// is not supported on this platform.
+func tryLockFile(f *os.File) (bool, error) {
+	return false, errors.NotSupportedf("file leases")
+}
@@ -0,0 +1,96 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package worker_test
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/worker/v3"
+)
+
+type FileLeaseSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&FileLeaseSuite{})
+
+func (*FileLeaseSuite) TestClaimAndRelease(c *gc.C) {
+	path := filepath.Join(c.MkDir(), "lease")
+	lease0 := worker.NewFileLease(worker.FileLeaseParams{Path: path})
+	lease1 := worker.NewFileLease(worker.FileLeaseParams{
+		Path:       path,
+		RetryDelay: time.Millisecond,
+	})
+	lost, err := lease0.Claim(context.Background())
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(lost, gc.IsNil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), shortWait)
+	defer cancel()
+	_, err = lease1.Claim(ctx)
+	c.Assert(err, gc.Equals, context.DeadlineExceeded)
+
+	c.Assert(lease0.Release(), jc.ErrorIsNil)
+	_, err = lease1.Claim(context.Background())
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(lease1.Release(), jc.ErrorIsNil)
+	c.Assert(lease1.Release(), jc.ErrorIsNil)
+}
+
+func (*FileLeaseSuite) TestClaimWhenHeld(c *gc.C) {
+	lease := worker.NewFileLease(worker.FileLeaseParams{
+		Path: filepath.Join(c.MkDir(), "lease"),
+	})
+	_, err := lease.Claim(context.Background())
+	c.Assert(err, jc.ErrorIsNil)
+	defer lease.Release()
+	_, err = lease.Claim(context.Background())
+	c.Assert(err, gc.ErrorMatches, `lease ".*" already held`)
+}
+
+func (*FileLeaseSuite) TestClaimDoesNotBlockLease(c *gc.C) {
+	path := filepath.Join(c.MkDir(), "lease")
+	lease0 := worker.NewFileLease(worker.FileLeaseParams{Path: path})
+	_, err := lease0.Claim(context.Background())
+	c.Assert(err, jc.ErrorIsNil)
+	defer lease0.Release()
+
+	lease1 := worker.NewFileLease(worker.FileLeaseParams{
+		Path:       path,
+		RetryDelay: time.Millisecond,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	claimed := make(chan error, 1)
+	go func() {
+		_, err := lease1.Claim(ctx)
+		claimed <- err
+	}()
+	select {
+	case err := <-claimed:
+		c.Fatalf("claim returned early: %v", err)
+	case <-time.After(shortWait):
+	}
+
+	// The pending claim doesn't stop the lease being used.
+	_, err = lease1.Claim(context.Background())
+	c.Assert(err, gc.ErrorMatches, `lease ".*" already being claimed`)
+	c.Assert(lease1.Release(), jc.ErrorIsNil)
+
+	cancel()
+	select {
+	case err := <-claimed:
+		c.Assert(err, gc.Equals, context.Canceled)
+	case <-time.After(longWait):
+		c.Fatalf("claim never returned")
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package worker
+
+import "time"
+
+// ExitClass classifies the way that a worker in a Runner exited.
+type ExitClass string
+
+const (
+	// ExitClean is used when a worker exited without error.
+	ExitClean ExitClass = "clean"
+
+	// ExitStartError is used when a worker's start function
+	// returned a non-fatal error.
+	ExitStartError ExitClass = "start-error"
+
+	// ExitError is used when a worker exited with a non-fatal error.
+	ExitError ExitClass = "error"
+
+	// ExitFatal is used when a worker exited, or failed to start,
+	// with a fatal error.
+	ExitFatal ExitClass = "fatal"
+)
+
+// Metrics defines a type for recording the worker life cycle in a Runner.
+type Metrics interface {
+	// RecordStart is called when the worker with the given id
+	// has been started.
+	RecordStart(id string)
+
+	// RecordRestart is called when the worker with the given id
+	// is scheduled to be restarted.
+	RecordRestart(id string)
+
+	// RecordExit is called when the worker with the given id
+	// has exited, or failed to start.
+	RecordExit(id string, class ExitClass)
+
+	// RecordFatal is called when the worker with the given id
+	// has caused the runner to stop.
+	RecordFatal(id string)
+
+	// RecordExhausted is called when the worker with the given id
+	// has used up all of its restarts, and won't be restarted
+	// until it is reset.
+	RecordExhausted(id string)
+
+	// RecordUptime is called with the length of time that the
+	// worker with the given id was running, when it exits.
+	RecordUptime(id string, uptime time.Duration)
+}
+
+// noopMetrics gives a metric that doesn't do anything.
+type noopMetrics struct{}
+
+func (noopMetrics) RecordStart(string)                 {}
+func (noopMetrics) RecordRestart(string)               {}
+func (noopMetrics) RecordExit(string, ExitClass)       {}
+func (noopMetrics) RecordFatal(string)                 {}
+func (noopMetrics) RecordExhausted(string)             {}
+func (noopMetrics) RecordUptime(string, time.Duration) {}
+
+// DefaultMetrics returns a metrics implementation that performs no operations,
+// but can be used for scenarios where metrics output isn't required.
+func DefaultMetrics() Metrics {
+	return noopMetrics{}
+}
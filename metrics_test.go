@@ -0,0 +1,173 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package worker_test
+
+import (
+	"time"
+
+	"github.com/juju/clock/testclock"
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/worker/v3"
+)
+
+type MetricsSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&MetricsSuite{})
+
+func (*MetricsSuite) TestRecordsLifecycle(c *gc.C) {
+	clock := testclock.NewClock(time.Now())
+	metrics := &stubMetrics{}
+	started := make(chan worker.Worker, 1)
+	runner := worker.NewRunnerWithNotify(worker.RunnerParams{
+		IsFatal: func(err error) bool {
+			return err.Error() == "fatal"
+		},
+		RestartDelay: time.Second,
+		Clock:        clock,
+		Metrics:      metrics,
+	}, started)
+	starter := newTestWorkerStarter()
+	err := runner.StartWorker("id", starter.start)
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, true)
+	select {
+	case <-started:
+	case <-time.After(longWait):
+		c.Fatalf("timed out waiting for worker to be started")
+	}
+
+	clock.Advance(time.Minute)
+	starter.die <- errors.New("boom")
+	starter.assertStarted(c, false)
+	select {
+	case <-clock.Alarms():
+	case <-time.After(longWait):
+		c.Fatalf("runner never slept")
+	}
+	clock.Advance(time.Second)
+	starter.assertStarted(c, true)
+
+	starter.die <- errors.New("fatal")
+	c.Assert(runner.Wait(), gc.ErrorMatches, "fatal")
+
+	metrics.Stub.CheckCalls(c, []testing.StubCall{
+		{FuncName: "RecordStart", Args: []interface{}{"id"}},
+		{FuncName: "RecordUptime", Args: []interface{}{"id", time.Minute}},
+		{FuncName: "RecordExit", Args: []interface{}{"id", worker.ExitError}},
+		{FuncName: "RecordRestart", Args: []interface{}{"id"}},
+		{FuncName: "RecordStart", Args: []interface{}{"id"}},
+		{FuncName: "RecordUptime", Args: []interface{}{"id", time.Duration(0)}},
+		{FuncName: "RecordExit", Args: []interface{}{"id", worker.ExitFatal}},
+		{FuncName: "RecordFatal", Args: []interface{}{"id"}},
+	})
+}
+
+func (*MetricsSuite) TestRecordsStartError(c *gc.C) {
+	metrics := &stubMetrics{}
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:      noneFatal,
+		RestartDelay: time.Millisecond,
+		Metrics:      metrics,
+	})
+	starter := newTestWorkerStarter()
+	starter.startErr = errors.New("boom")
+	err := runner.StartWorker("id", starter.start, worker.WithRestartPolicy(worker.RestartPolicy{
+		Mode: worker.RestartNever,
+	}))
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, false)
+	c.Assert(worker.Stop(runner), jc.ErrorIsNil)
+
+	metrics.Stub.CheckCalls(c, []testing.StubCall{
+		{FuncName: "RecordExit", Args: []interface{}{"id", worker.ExitStartError}},
+	})
+}
+
+func (*MetricsSuite) TestRecordsExhausted(c *gc.C) {
+	metrics := &stubMetrics{}
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:      noneFatal,
+		RestartDelay: time.Millisecond,
+		Metrics:      metrics,
+	})
+	starter := newTestWorkerStarter()
+	starter.startErr = errors.New("boom")
+	err := runner.StartWorker("id", starter.start, worker.WithRestartPolicy(worker.RestartPolicy{
+		MaxRestarts: 1,
+	}))
+	c.Assert(err, jc.ErrorIsNil)
+	waitWorkerReport(c, runner, "id", worker.KeyState, "exhausted")
+	c.Assert(worker.Stop(runner), jc.ErrorIsNil)
+
+	metrics.Stub.CheckCalls(c, []testing.StubCall{
+		{FuncName: "RecordExit", Args: []interface{}{"id", worker.ExitStartError}},
+		{FuncName: "RecordRestart", Args: []interface{}{"id"}},
+		{FuncName: "RecordExit", Args: []interface{}{"id", worker.ExitStartError}},
+		{FuncName: "RecordExhausted", Args: []interface{}{"id"}},
+	})
+}
+
+func (*MetricsSuite) TestStopDuringRestartDelay(c *gc.C) {
+	clock := testclock.NewClock(time.Now())
+	metrics := &stubMetrics{}
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:      noneFatal,
+		RestartDelay: time.Hour,
+		Clock:        clock,
+		Metrics:      metrics,
+	})
+	defer worker.Stop(runner)
+	starter := newTestWorkerStarter()
+	starter.startErr = errors.New("boom")
+	err := runner.StartWorker("id", starter.start)
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, false)
+	select {
+	case <-clock.Alarms():
+	case <-time.After(longWait):
+		c.Fatalf("runner never slept")
+	}
+
+	// Abandoning the restart doesn't count as an exit.
+	err = runner.StopAndRemoveWorker("id", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	metrics.Stub.CheckCalls(c, []testing.StubCall{
+		{FuncName: "RecordExit", Args: []interface{}{"id", worker.ExitStartError}},
+		{FuncName: "RecordRestart", Args: []interface{}{"id"}},
+	})
+}
+
+type stubMetrics struct {
+	testing.Stub
+}
+
+func (m *stubMetrics) RecordStart(id string) {
+	m.AddCall("RecordStart", id)
+}
+
+func (m *stubMetrics) RecordRestart(id string) {
+	m.AddCall("RecordRestart", id)
+}
+
+func (m *stubMetrics) RecordExit(id string, class worker.ExitClass) {
+	m.AddCall("RecordExit", id, class)
+}
+
+func (m *stubMetrics) RecordFatal(id string) {
+	m.AddCall("RecordFatal", id)
+}
+
+func (m *stubMetrics) RecordExhausted(id string) {
+	m.AddCall("RecordExhausted", id)
+}
+
+func (m *stubMetrics) RecordUptime(id string, uptime time.Duration) {
+	m.AddCall("RecordUptime", id, uptime)
+}
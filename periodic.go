@@ -0,0 +1,257 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/juju/clock"
+	"github.com/juju/errors"
+	"gopkg.in/tomb.v2"
+)
+
+// OverlapPolicy describes what a PeriodicWorker does when its job
+// is due to run while the previous run is still going.
+type OverlapPolicy int
+
+const (
+	// OverlapSkip skips the run. This is the default.
+	OverlapSkip OverlapPolicy = iota
+
+	// OverlapDelay runs the job as soon as the previous run has
+	// finished. Any further runs that become due while waiting
+	// are combined with it.
+	OverlapDelay
+
+	// OverlapAllow runs the job concurrently with the previous run.
+	OverlapAllow
+)
+
+// MissedRunPolicy describes what a PeriodicWorker does when it finds
+// that more than one scheduled run has passed while it was waiting,
+// for example because the machine was suspended or the clock jumped.
+type MissedRunPolicy int
+
+const (
+	// MissedRunSkip skips the missed runs, and waits for the
+	// next scheduled run. This is the default.
+	MissedRunSkip MissedRunPolicy = iota
+
+	// MissedRunOnce runs the job once, straight away, in place
+	// of all the missed runs.
+	MissedRunOnce
+)
+
+// PeriodicWorkerParams holds the parameters for a
+// NewPeriodicWorker call.
+type PeriodicWorkerParams struct {
+	// Job is called to run the job. The context is cancelled when
+	// the worker is killed. If the job returns an error, other than
+	// the context's error, the worker is killed with that error.
+	Job func(ctx context.Context) error
+
+	// Schedule determines when the job runs.
+	Schedule Schedule
+
+	// Overlap determines what happens when the job is due
+	// while it is still running.
+	Overlap OverlapPolicy
+
+	// MissedRun determines what happens when scheduled
+	// runs of the job have been missed.
+	MissedRun MissedRunPolicy
+
+	// Clock is used for timekeeping. If it's nil, clock.WallClock
+	// will be used.
+	Clock Clock
+}
+
+// Validate returns an error if the params are not valid.
+func (p PeriodicWorkerParams) Validate() error {
+	if p.Job == nil {
+		return errors.NotValidf("nil Job")
+	}
+	if p.Schedule == nil {
+		return errors.NotValidf("nil Schedule")
+	}
+	switch p.Overlap {
+	case OverlapSkip, OverlapDelay, OverlapAllow:
+	default:
+		return errors.NotValidf("overlap policy %d", p.Overlap)
+	}
+	switch p.MissedRun {
+	case MissedRunSkip, MissedRunOnce:
+	default:
+		return errors.NotValidf("missed run policy %d", p.MissedRun)
+	}
+	return nil
+}
+
+// PeriodicWorker is a Worker that runs a job on a schedule.
+type PeriodicWorker struct {
+	tomb   tomb.Tomb
+	params PeriodicWorkerParams
+
+	// finished receives a value when a run of the job finishes.
+	finished chan struct{}
+
+	// mu guards the fields below it, which are
+	// maintained by the loop goroutine.
+	mu sync.Mutex
+
+	// lastRun holds when the job was last started, and
+	// nextRun holds when it is next scheduled to run.
+	lastRun time.Time
+	nextRun time.Time
+
+	// running holds the number of runs of the job
+	// that are in progress.
+	running int
+
+	// runCount and skipCount hold the number of times that
+	// the job has been started, and the number of runs
+	// that have been skipped.
+	runCount  int
+	skipCount int
+}
+
+// NewPeriodicWorker returns a new PeriodicWorker that runs
+// a job as described by the given params.
+func NewPeriodicWorker(p PeriodicWorkerParams) (*PeriodicWorker, error) {
+	if err := p.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if p.Clock == nil {
+		p.Clock = clock.WallClock
+	}
+	w := &PeriodicWorker{
+		params:   p,
+		finished: make(chan struct{}),
+		nextRun:  p.Schedule.Next(p.Clock.Now()),
+	}
+	w.tomb.Go(w.loop)
+	return w, nil
+}
+
+// Kill implements Worker.Kill.
+func (w *PeriodicWorker) Kill() {
+	w.tomb.Kill(nil)
+}
+
+// Wait implements Worker.Wait.
+func (w *PeriodicWorker) Wait() error {
+	return w.tomb.Wait()
+}
+
+// Report implements Reporter.
+func (w *PeriodicWorker) Report() map[string]interface{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	state := "waiting"
+	if w.running > 0 {
+		state = "running"
+	}
+	report := map[string]interface{}{
+		KeyState:     state,
+		KeyRunCount:  w.runCount,
+		KeySkipCount: w.skipCount,
+	}
+	if !w.lastRun.IsZero() {
+		report[KeyLastRun] = w.lastRun.UTC().Format(reportTimeFormat)
+	}
+	if !w.nextRun.IsZero() {
+		report[KeyNextRun] = w.nextRun.UTC().Format(reportTimeFormat)
+	}
+	return report
+}
+
+func (w *PeriodicWorker) loop() error {
+	ctx := w.tomb.Context(nil)
+	schedule := w.params.Schedule
+	w.mu.Lock()
+	next := w.nextRun
+	w.mu.Unlock()
+	var due <-chan time.Time
+	if !next.IsZero() {
+		due = w.params.Clock.After(next.Sub(w.params.Clock.Now()))
+	}
+	// pending holds whether a run is waiting
+	// for the current run to finish.
+	pending := false
+	for {
+		select {
+		case <-w.tomb.Dying():
+			return nil
+
+		case <-w.finished:
+			w.mu.Lock()
+			w.running--
+			running := w.running
+			w.mu.Unlock()
+			if pending && running == 0 {
+				pending = false
+				w.run(ctx)
+			}
+
+		case <-due:
+			now := w.params.Clock.Now()
+			skip := false
+			if after := schedule.Next(next); !after.IsZero() && !after.After(now) {
+				// We've missed at least one run.
+				skip = w.params.MissedRun == MissedRunSkip
+				next = schedule.Next(now)
+			} else {
+				next = after
+			}
+			due = nil
+			if !next.IsZero() {
+				due = w.params.Clock.After(next.Sub(now))
+			}
+			w.setNextRun(next)
+
+			w.mu.Lock()
+			running := w.running
+			w.mu.Unlock()
+			switch {
+			case skip:
+			case running == 0 || w.params.Overlap == OverlapAllow:
+				w.run(ctx)
+				continue
+			case w.params.Overlap == OverlapDelay:
+				pending = true
+				continue
+			}
+			w.mu.Lock()
+			w.skipCount++
+			w.mu.Unlock()
+		}
+	}
+}
+
+// run starts a run of the job.
+func (w *PeriodicWorker) run(ctx context.Context) {
+	w.mu.Lock()
+	w.running++
+	w.runCount++
+	w.lastRun = w.params.Clock.Now()
+	w.mu.Unlock()
+	w.tomb.Go(func() error {
+		if err := w.params.Job(ctx); err != nil && errors.Cause(err) != ctx.Err() {
+			return errors.Trace(err)
+		}
+		select {
+		case w.finished <- struct{}{}:
+		case <-w.tomb.Dying():
+		}
+		return nil
+	})
+}
+
+func (w *PeriodicWorker) setNextRun(next time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.nextRun = next
+}
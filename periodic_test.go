@@ -0,0 +1,282 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package worker_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/juju/clock/testclock"
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/worker/v3"
+	"github.com/juju/worker/v3/workertest"
+)
+
+type PeriodicWorkerSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&PeriodicWorkerSuite{})
+
+// testJob is a job that reports when it runs, and
+// doesn't finish until it's told to.
+type testJob struct {
+	started chan struct{}
+	finish  chan error
+}
+
+func newTestJob() *testJob {
+	return &testJob{
+		started: make(chan struct{}, 10),
+		finish:  make(chan error, 10),
+	}
+}
+
+func (job *testJob) run(ctx context.Context) error {
+	job.started <- struct{}{}
+	select {
+	case err := <-job.finish:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (job *testJob) assertStarted(c *gc.C) {
+	select {
+	case <-job.started:
+	case <-time.After(longWait):
+		c.Fatalf("timed out waiting for job to start")
+	}
+}
+
+func (job *testJob) assertNotStarted(c *gc.C) {
+	select {
+	case <-job.started:
+		c.Fatalf("job started unexpectedly")
+	case <-time.After(shortWait):
+	}
+}
+
+// waitIdle waits until no runs of the worker's job are in progress.
+func waitIdle(c *gc.C, w *worker.PeriodicWorker) {
+	timeout := time.After(longWait)
+	for w.Report()[worker.KeyState] != "waiting" {
+		select {
+		case <-timeout:
+			c.Fatalf("timed out waiting for job to finish")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+// every returns a schedule that runs a job at the given interval.
+func every(c *gc.C, interval time.Duration) worker.Schedule {
+	schedule, err := worker.Every(interval)
+	c.Assert(err, jc.ErrorIsNil)
+	return schedule
+}
+
+func (s *PeriodicWorkerSuite) newWorker(c *gc.C, p worker.PeriodicWorkerParams) *worker.PeriodicWorker {
+	w, err := worker.NewPeriodicWorker(p)
+	c.Assert(err, jc.ErrorIsNil)
+	s.AddCleanup(func(c *gc.C) { workertest.DirtyKill(c, w) })
+	return w
+}
+
+func (s *PeriodicWorkerSuite) TestRunsOnSchedule(c *gc.C) {
+	t0 := time.Date(2022, 2, 3, 4, 5, 6, 0, time.UTC)
+	clock := testclock.NewClock(t0)
+	job := newTestJob()
+	w := s.newWorker(c, worker.PeriodicWorkerParams{
+		Job:      job.run,
+		Schedule: every(c, time.Minute),
+		Clock:    clock,
+	})
+	c.Assert(w.Report(), jc.DeepEquals, map[string]interface{}{
+		worker.KeyState:     "waiting",
+		worker.KeyRunCount:  0,
+		worker.KeySkipCount: 0,
+		worker.KeyNextRun:   "2022-02-03 04:06:06",
+	})
+	for i := 0; i < 2; i++ {
+		err := clock.WaitAdvance(time.Minute, longWait, 1)
+		c.Assert(err, jc.ErrorIsNil)
+		job.assertStarted(c)
+		job.finish <- nil
+		waitIdle(c, w)
+	}
+	workertest.CheckAlive(c, w)
+	c.Assert(w.Report(), jc.DeepEquals, map[string]interface{}{
+		worker.KeyState:     "waiting",
+		worker.KeyRunCount:  2,
+		worker.KeySkipCount: 0,
+		worker.KeyLastRun:   "2022-02-03 04:07:06",
+		worker.KeyNextRun:   "2022-02-03 04:08:06",
+	})
+	workertest.CleanKill(c, w)
+}
+
+func (s *PeriodicWorkerSuite) TestOverlapSkip(c *gc.C) {
+	clock := testclock.NewClock(time.Now())
+	job := newTestJob()
+	w := s.newWorker(c, worker.PeriodicWorkerParams{
+		Job:      job.run,
+		Schedule: every(c, time.Minute),
+		Clock:    clock,
+	})
+	err := clock.WaitAdvance(time.Minute, longWait, 1)
+	c.Assert(err, jc.ErrorIsNil)
+	job.assertStarted(c)
+	err = clock.WaitAdvance(time.Minute, longWait, 1)
+	c.Assert(err, jc.ErrorIsNil)
+	job.assertNotStarted(c)
+	c.Assert(w.Report()[worker.KeySkipCount], gc.Equals, 1)
+	c.Assert(w.Report()[worker.KeyState], gc.Equals, "running")
+
+	// Finishing the job doesn't run it again.
+	job.finish <- nil
+	job.assertNotStarted(c)
+	workertest.CleanKill(c, w)
+}
+
+func (s *PeriodicWorkerSuite) TestOverlapDelay(c *gc.C) {
+	clock := testclock.NewClock(time.Now())
+	job := newTestJob()
+	w := s.newWorker(c, worker.PeriodicWorkerParams{
+		Job:      job.run,
+		Schedule: every(c, time.Minute),
+		Overlap:  worker.OverlapDelay,
+		Clock:    clock,
+	})
+	err := clock.WaitAdvance(time.Minute, longWait, 1)
+	c.Assert(err, jc.ErrorIsNil)
+	job.assertStarted(c)
+	for i := 0; i < 2; i++ {
+		err = clock.WaitAdvance(time.Minute, longWait, 1)
+		c.Assert(err, jc.ErrorIsNil)
+	}
+	job.assertNotStarted(c)
+
+	// The delayed runs are combined into one.
+	job.finish <- nil
+	job.assertStarted(c)
+	job.finish <- nil
+	job.assertNotStarted(c)
+	c.Assert(w.Report()[worker.KeyRunCount], gc.Equals, 2)
+	workertest.CleanKill(c, w)
+}
+
+func (s *PeriodicWorkerSuite) TestOverlapAllow(c *gc.C) {
+	clock := testclock.NewClock(time.Now())
+	job := newTestJob()
+	w := s.newWorker(c, worker.PeriodicWorkerParams{
+		Job:      job.run,
+		Schedule: every(c, time.Minute),
+		Overlap:  worker.OverlapAllow,
+		Clock:    clock,
+	})
+	for i := 0; i < 2; i++ {
+		err := clock.WaitAdvance(time.Minute, longWait, 1)
+		c.Assert(err, jc.ErrorIsNil)
+		job.assertStarted(c)
+	}
+	workertest.CleanKill(c, w)
+}
+
+func (s *PeriodicWorkerSuite) TestMissedRunSkip(c *gc.C) {
+	t0 := time.Date(2022, 2, 3, 4, 5, 6, 0, time.UTC)
+	clock := testclock.NewClock(t0)
+	job := newTestJob()
+	w := s.newWorker(c, worker.PeriodicWorkerParams{
+		Job:      job.run,
+		Schedule: every(c, time.Minute),
+		Clock:    clock,
+	})
+	err := clock.WaitAdvance(3*time.Minute, longWait, 1)
+	c.Assert(err, jc.ErrorIsNil)
+	job.assertNotStarted(c)
+	c.Assert(w.Report()[worker.KeyNextRun], gc.Equals, "2022-02-03 04:09:06")
+	err = clock.WaitAdvance(time.Minute, longWait, 1)
+	c.Assert(err, jc.ErrorIsNil)
+	job.assertStarted(c)
+	job.finish <- nil
+	workertest.CleanKill(c, w)
+}
+
+func (s *PeriodicWorkerSuite) TestMissedRunOnce(c *gc.C) {
+	clock := testclock.NewClock(time.Now())
+	job := newTestJob()
+	w := s.newWorker(c, worker.PeriodicWorkerParams{
+		Job:       job.run,
+		Schedule:  every(c, time.Minute),
+		MissedRun: worker.MissedRunOnce,
+		Clock:     clock,
+	})
+	err := clock.WaitAdvance(3*time.Minute, longWait, 1)
+	c.Assert(err, jc.ErrorIsNil)
+	job.assertStarted(c)
+	job.finish <- nil
+	job.assertNotStarted(c)
+	workertest.CleanKill(c, w)
+}
+
+func (s *PeriodicWorkerSuite) TestJobError(c *gc.C) {
+	clock := testclock.NewClock(time.Now())
+	job := newTestJob()
+	w := s.newWorker(c, worker.PeriodicWorkerParams{
+		Job:      job.run,
+		Schedule: every(c, time.Minute),
+		Clock:    clock,
+	})
+	err := clock.WaitAdvance(time.Minute, longWait, 1)
+	c.Assert(err, jc.ErrorIsNil)
+	job.assertStarted(c)
+	job.finish <- errors.New("boom")
+	err = workertest.CheckKilled(c, w)
+	c.Assert(err, gc.ErrorMatches, "boom")
+}
+
+func (s *PeriodicWorkerSuite) TestKillCancelsJob(c *gc.C) {
+	clock := testclock.NewClock(time.Now())
+	job := newTestJob()
+	w := s.newWorker(c, worker.PeriodicWorkerParams{
+		Job:      job.run,
+		Schedule: every(c, time.Minute),
+		Clock:    clock,
+	})
+	err := clock.WaitAdvance(time.Minute, longWait, 1)
+	c.Assert(err, jc.ErrorIsNil)
+	job.assertStarted(c)
+	workertest.CleanKill(c, w)
+}
+
+func (*PeriodicWorkerSuite) TestValidate(c *gc.C) {
+	job := func(context.Context) error { return nil }
+	for i, test := range []struct {
+		params worker.PeriodicWorkerParams
+		err    string
+	}{{
+		params: worker.PeriodicWorkerParams{Schedule: every(c, time.Minute)},
+		err:    "nil Job not valid",
+	}, {
+		params: worker.PeriodicWorkerParams{Job: job},
+		err:    "nil Schedule not valid",
+	}, {
+		params: worker.PeriodicWorkerParams{Job: job, Schedule: every(c, time.Minute), Overlap: -1},
+		err:    "overlap policy -1 not valid",
+	}, {
+		params: worker.PeriodicWorkerParams{Job: job, Schedule: every(c, time.Minute), MissedRun: 5},
+		err:    "missed run policy 5 not valid",
+	}} {
+		c.Logf("test %d", i)
+		_, err := worker.NewPeriodicWorker(test.params)
+		c.Check(err, gc.ErrorMatches, test.err)
+		c.Check(err, jc.Satisfies, errors.IsNotValid)
+	}
+}
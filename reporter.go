@@ -31,4 +31,59 @@ const (
 
 	// KeyLastStart holds the time of when the worker was last started.
 	KeyLastStart = "started"
+
+	// KeyStartCount holds the number of times the worker has been started.
+	KeyStartCount = "start-count"
+
+	// KeyError holds the most recent error that the worker exited with.
+	KeyError = "error"
+
+	// KeyErrorTime holds the time of when the worker last exited
+	// with an error.
+	KeyErrorTime = "error-time"
+
+	// KeyErrorStack holds the stack trace of the panic that caused
+	// the worker's most recent error, if it panicked.
+	KeyErrorStack = "error-stack"
+
+	// KeyHealth holds the result of the most recent health check
+	// of the worker, if it has been checked.
+	KeyHealth = "health"
+
+	// KeyNextStart holds the time of when a worker that is waiting
+	// to be restarted, or scheduled to start, will next be started.
+	KeyNextStart = "next-start"
+
+	// KeyStoppingFor holds the length of time that a worker has
+	// been stopping for.
+	KeyStoppingFor = "stopping-for"
+
+	// KeyRestartCount holds the number of times the worker has been
+	// restarted.
+	KeyRestartCount = "restart-count"
+
+	// KeyLease holds whether a singleton worker's
+	// lease is held by the running worker.
+	KeyLease = "lease"
+
+	// KeyLabels holds the labels attached to the worker.
+	KeyLabels = "labels"
+
+	// KeyMaxRestarts holds the maximum number of times the worker
+	// will be restarted.
+	KeyMaxRestarts = "max-restarts"
+
+	// KeyLastRun holds the time of when a periodic job was last run.
+	KeyLastRun = "last-run"
+
+	// KeyNextRun holds the time of when a periodic job is next
+	// scheduled to run.
+	KeyNextRun = "next-run"
+
+	// KeyRunCount holds the number of times a periodic job has run.
+	KeyRunCount = "run-count"
+
+	// KeySkipCount holds the number of runs of a periodic job
+	// that have been skipped.
+	KeySkipCount = "skip-count"
 )
@@ -4,6 +4,12 @@
 package worker
 
 import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime/debug"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -22,12 +28,117 @@ var (
 	ErrDead    = errors.New("worker runner is not running")
 )
 
+// ErrRestartNow can be returned by a worker run by a Runner, or by its
+// start function, to indicate that it should be restarted immediately,
+// instead of waiting for its restart delay.
+var ErrRestartNow = errors.New("restart immediately")
+
+// ErrRemove can be returned by a worker run by a Runner, or by its
+// start function, to indicate that it should never run again, and
+// should be removed from the runner.
+var ErrRemove = errors.New("worker permanently finished")
+
+// RestartAfter returns an error that can be returned by a worker run by
+// a Runner, or by its start function, to indicate that it should be
+// restarted after the given delay, instead of its usual restart delay.
+func RestartAfter(delay time.Duration) error {
+	return &restartAfterError{delay: delay}
+}
+
+// restartAfterError is the error returned by RestartAfter.
+type restartAfterError struct {
+	delay time.Duration
+}
+
+// Error implements error.
+func (e *restartAfterError) Error() string {
+	return fmt.Sprintf("restart after %v", e.delay)
+}
+
+// isRestartSentinel returns whether err is one of the errors
+// that a worker can use to control how it's restarted.
+func isRestartSentinel(err error) bool {
+	cause := errors.Cause(err)
+	if _, ok := cause.(*restartAfterError); ok {
+		return true
+	}
+	return cause == ErrRestartNow || cause == ErrRemove
+}
+
+// StuckWorkersError is returned from Runner.Wait when the runner
+// has given up waiting for some of its workers to stop.
+type StuckWorkersError struct {
+	// IDs holds the ids of the workers that had not stopped,
+	// in sorted order.
+	IDs []string
+
+	// Timeout holds the length of time that the runner waited.
+	Timeout time.Duration
+
+	// Err holds the error that the runner would otherwise
+	// have returned, if any.
+	Err error
+}
+
+// Error implements error.
+func (e *StuckWorkersError) Error() string {
+	msg := fmt.Sprintf("workers still stopping after %v: %s", e.Timeout, strings.Join(e.IDs, ", "))
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// FatalErrors is returned from Runner.Wait when
+// RunnerParams.AggregateFatalErrors is set and one or more workers
+// have exited with fatal errors.
+type FatalErrors struct {
+	// Errors holds all the fatal errors, keyed by worker id.
+	Errors map[string]error
+
+	// Err holds the most important of the errors, as
+	// determined by RunnerParams.MoreImportant.
+	Err error
+}
+
+// Error implements error.
+func (e *FatalErrors) Error() string {
+	ids := make([]string, 0, len(e.Errors))
+	for id := range e.Errors {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	msgs := make([]string, len(ids))
+	for i, id := range ids {
+		msgs[i] = fmt.Sprintf("worker %q: %v", id, e.Errors[id])
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Cause returns the cause of the most important error,
+// so that errors.Cause can be used on a FatalErrors.
+func (e *FatalErrors) Cause() error {
+	return errors.Cause(e.Err)
+}
+
+// Unwrap returns the most important error.
+func (e *FatalErrors) Unwrap() error {
+	return e.Err
+}
+
 // Runner runs a set of workers, restarting them as necessary
 // when they fail.
 type Runner struct {
 	tomb     tomb.Tomb
 	startc   chan startReq
+	setc     chan setReq
 	stopc    chan string
+	stopSelc chan Selector
+	restartc chan restartReq
+	resetc   chan restartReq
+	pausec   chan pauseReq
+	queuec   chan queueReq
+	healthc  chan healthInfo
 	donec    chan doneInfo
 	startedc chan startInfo
 
@@ -45,6 +156,26 @@ type Runner struct {
 	// when the runner finally exits.
 	finalError error
 
+	// finalErrorMoreImportant is maintained by the run goroutine.
+	// It holds the function used by the worker that returned
+	// finalError to decide whether an error is more important.
+	finalErrorMoreImportant func(err0, err1 error) bool
+
+	// fatalErrors is maintained by the run goroutine.
+	// It holds all the fatal errors, keyed by worker id,
+	// when RunnerParams.AggregateFatalErrors is set.
+	fatalErrors map[string]error
+
+	// startQueue is maintained by the run goroutine.
+	// It holds the ids of the workers waiting to be
+	// allowed to start, in order.
+	startQueue []string
+
+	// starting is maintained by the run goroutine.
+	// It holds the number of start functions that
+	// are currently running.
+	starting int
+
 	// mu guards the fields below it. Note that the
 	// run goroutine only locks the mutex when
 	// it changes workers, not when it reads it. It can do this
@@ -58,6 +189,16 @@ type Runner struct {
 	// workers holds the current set of workers.
 	workers map[string]*workerInfo
 
+	// subsMu guards the fields below it.
+	subsMu sync.Mutex
+
+	// subs holds the current event subscriptions.
+	subs map[*Subscription]bool
+
+	// subsClosed is set when the runner has finished
+	// and no more events will be sent.
+	subsClosed bool
+
 	// notifyStarted is used only for test synchronisation.
 	// As the worker startInfo values are processed, the worker is sent
 	// down this channel if this channel is not nil.
@@ -77,7 +218,7 @@ type workerInfo struct {
 	// start holds the function to create the worker.
 	// If this is nil, the worker has been stopped
 	// and will be removed when its goroutine exits.
-	start func() (Worker, error)
+	start func(context.Context) (Worker, error)
 
 	// restartDelay holds the length of time that runWorker
 	// will wait before calling the start function.
@@ -88,30 +229,190 @@ type workerInfo struct {
 	// still exist while this is true.
 	stopping bool
 
+	// stopRequested holds when the worker was
+	// asked to stop.
+	stopRequested time.Time
+
+	// restarting holds whether the worker has been
+	// asked to restart by RestartWorker. It will be
+	// started again as soon as it exits.
+	restarting bool
+
 	// done is used to signal when the worker has finished
-	// running and is removed from the runner.
-	done chan struct{}
+	// running and is removed from the runner. The error
+	// that the worker finished with is sent on it.
+	done chan error
+
+	// cancel cancels the context passed to the current
+	// runWorker goroutine, interrupting any pending restart
+	// of the worker. It is replaced each time the
+	// runWorker goroutine is started.
+	cancel context.CancelFunc
+
+	// attempts holds the number of times that the
+	// runWorker goroutine has been started.
+	attempts int
 
 	// started holds the time the worker was started.
 	started time.Time
+
+	// running holds whether the current worker has
+	// been started and has not yet exited.
+	running bool
+
+	// policy holds the restart policy for the worker.
+	policy RestartPolicy
+
+	// stage holds the shutdown stage of the worker.
+	stage int
+
+	// labels holds the labels attached to the worker.
+	labels map[string]string
+
+	// lease holds the lease that must be held while the
+	// worker runs, if it is a singleton worker.
+	lease Lease
+
+	// isFatal, moreImportant and filter hold the worker's
+	// own versions of the corresponding RunnerParams
+	// functions, if it has them.
+	isFatal       func(error) bool
+	moreImportant func(err0, err1 error) bool
+	filter        func(error) error
+
+	// restarts holds the number of times the worker
+	// has been restarted.
+	restarts int
+
+	// startCount holds the number of times the worker
+	// has been started.
+	startCount int
+
+	// err holds the most recent error that the worker
+	// exited with, and errTime holds when that happened.
+	err     error
+	errTime time.Time
+
+	// nextStart holds when the worker will be restarted,
+	// if it is waiting for its restart delay.
+	nextStart time.Time
+
+	// scheduled holds whether the worker is waiting
+	// for its first start, as set by WithStartDelay
+	// or WithStartAt.
+	scheduled bool
+
+	// recentErrors holds the number of consecutive times
+	// the worker has failed without staying up for longer
+	// than RunnerParams.BackoffResetTime.
+	recentErrors int
+
+	// failures holds the times of the worker's failures
+	// within RunnerParams.FailureWindow.
+	failures []time.Time
+
+	// failed holds whether the worker has failed too often
+	// and will not be restarted until it is reset.
+	failed bool
+
+	// exhausted holds whether the worker has used up all of
+	// its MaxRestarts and will not be restarted until it is reset.
+	exhausted bool
+
+	// paused holds whether the worker has been paused by
+	// PauseWorker and will not be restarted until it is resumed.
+	paused bool
+
+	// queued holds whether the worker is waiting for
+	// its turn to start, and grant holds the channel that
+	// will be closed when it may start.
+	queued bool
+	grant  chan struct{}
+
+	// holdsSlot holds whether the worker's start function
+	// counts towards RunnerParams.MaxConcurrentStarts.
+	holdsSlot bool
+
+	// health holds the result of the most recent health
+	// check of the current worker, and healthChecked holds
+	// whether it has been checked at all.
+	health        error
+	healthChecked bool
+
+	// healthFailures holds the number of consecutive
+	// health checks that the current worker has failed.
+	healthFailures int
 }
 
-func (i *workerInfo) status() string {
-	if i.stopping && i.worker != nil {
+// newContext creates the context for a new attempt
+// to start the worker, to be passed to runWorker.
+func (i *workerInfo) newContext(id string) context.Context {
+	i.attempts++
+	ctx, cancel := context.WithCancel(context.Background())
+	i.cancel = cancel
+	return withStartValues(ctx, id, i.attempts)
+}
+
+// status returns the state of the worker at the given time.
+func (i *workerInfo) status(now time.Time) string {
+	switch {
+	case i.stopping:
 		return "stopping"
-	}
-	if i.worker == nil {
+	case i.paused:
+		return "paused"
+	case i.running:
+		return "started"
+	case i.failed:
+		return "failed"
+	case i.exhausted:
+		return "exhausted"
+	case i.queued:
+		return "queued"
+	case i.scheduled && i.nextStart.After(now):
+		return "scheduled"
+	case i.nextStart.After(now):
 		return "stopped"
 	}
-	return "started"
+	return "starting"
 }
 
 type startReq struct {
 	id    string
-	start func() (Worker, error)
+	start func(context.Context) (Worker, error)
+	adopt Worker
+	opts  startOptions
+	reply chan error
+}
+
+type setReq struct {
+	desired map[string]func(context.Context) (Worker, error)
+	opts    startOptions
+	reply   chan SetWorkersResult
+}
+
+type restartReq struct {
+	id    string
+	start func(context.Context) (Worker, error)
+	reply chan error
+}
+
+type pauseReq struct {
+	id    string
+	pause bool
 	reply chan error
 }
 
+type queueReq struct {
+	id    string
+	grant chan struct{}
+}
+
+type healthInfo struct {
+	id      string
+	attempt int
+	err     error
+}
+
 type startInfo struct {
 	id     string
 	worker Worker
@@ -120,6 +421,15 @@ type startInfo struct {
 type doneInfo struct {
 	id  string
 	err error
+
+	// attempted holds whether an attempt was made to start
+	// the worker, as opposed to the attempt being abandoned
+	// before it got that far.
+	attempted bool
+
+	// leaseLost holds whether the worker was killed
+	// because its lease was lost.
+	leaseLost bool
 }
 
 // Logger represents the various logging methods used by the runner.
@@ -142,6 +452,13 @@ type RunnerParams struct {
 	// will be stopped and the runner itself will finish.
 	//
 	// If IsFatal is nil, all errors will be treated as fatal.
+	//
+	// IsFatal is also called for ErrRestartNow, ErrRemove and
+	// errors returned by RestartAfter, which only take effect
+	// if it returns false for them.
+	//
+	// A worker started with WithIsFatal uses its own
+	// function instead.
 	IsFatal func(error) bool
 
 	// When the runner exits because one or more
@@ -150,15 +467,40 @@ type RunnerParams struct {
 	// err0 is more important than err1.
 	//
 	// If MoreImportant is nil, the first error reported will be
-	// returned.
+	// returned. A worker started with WithMoreImportant uses its
+	// own function instead.
 	MoreImportant func(err0, err1 error) bool
 
+	// AggregateFatalErrors causes the runner to return all the fatal
+	// errors from its workers when it exits, as a *FatalErrors,
+	// rather than just the most important one.
+	AggregateFatalErrors bool
+
 	// RestartDelay holds the length of time the runner will
 	// wait after a worker has exited with a non-fatal error
 	// before it is restarted.
 	// If this is zero, DefaultRestartDelay will be used.
 	RestartDelay time.Duration
 
+	// BackoffFactor is the value used to multiply the restart
+	// delay for consecutive failures of a worker. If this is
+	// zero, the restart delay is not increased; otherwise it
+	// must be at least 1. When it is set,
+	// restart delays are also fuzzed by ±10% so that failing
+	// workers don't restart in lockstep.
+	BackoffFactor float64
+
+	// BackoffResetTime determines if a worker is running for longer than
+	// this time, then any failure will be treated as a 'fresh' failure,
+	// and the restart delay will revert to RestartDelay. It must
+	// not be negative.
+	BackoffResetTime time.Duration
+
+	// MaxDelay is the maximum delay that the runner will wait
+	// before restarting a worker after exponential backoff. If
+	// this is zero, there is no maximum. It must not be negative.
+	MaxDelay time.Duration
+
 	// Clock is used for timekeeping. If it's nil, clock.WallClock
 	// will be used.
 	Clock Clock
@@ -166,6 +508,289 @@ type RunnerParams struct {
 	// Logger is used to provide an implementation for where the logging
 	// messages go for the runner. If it's nil, no logging output.
 	Logger Logger
+
+	// Metrics is used to record the life cycle of the runner's
+	// workers. If it's nil, DefaultMetrics will be used.
+	Metrics Metrics
+
+	// StopTimeout holds the length of time that the runner will
+	// wait for its workers to stop when it is shutting down before
+	// logging the ids of the workers that are still stopping. The
+	// ids are logged again each time the timeout passes. If this
+	// is zero, the runner waits without logging.
+	StopTimeout time.Duration
+
+	// AbandonStuckWorkers causes the runner to give up waiting
+	// for workers to stop once StopTimeout has passed. Wait will
+	// then return a *StuckWorkersError naming the workers that
+	// were still stopping.
+	AbandonStuckWorkers bool
+
+	// RecoverPanics causes the runner to recover from a panic in a
+	// start function or in a worker's Wait method, treating it as an
+	// error returned by the worker, which is checked with IsFatal as
+	// usual. The error holds the stack of the panic, which is logged
+	// and included in the runner's report. If this is false, the
+	// panic is not recovered. Panics in goroutines started by the
+	// workers themselves can never be recovered by the runner.
+	RecoverPanics bool
+
+	// FailureThreshold holds the number of times that a worker may
+	// fail with a non-fatal error within FailureWindow before the
+	// runner stops restarting it. The worker is then left in the
+	// "failed" state until it is reset with ResetWorker. If this is
+	// zero, workers are restarted however often they fail.
+	FailureThreshold int
+
+	// FailureWindow holds the length of time over which failures
+	// are counted towards FailureThreshold. If this is zero, failures
+	// are never forgotten.
+	FailureWindow time.Duration
+
+	// MaxConcurrentStarts holds the maximum number of start
+	// functions that the runner will call at the same time. Any
+	// other workers that are ready to start are queued, and started
+	// in order as the running start functions return. If this is
+	// zero, start functions are always called straight away.
+	MaxConcurrentStarts int
+
+	// HealthCheckInterval holds the length of time between checks
+	// of the health of running workers that implement HealthChecker.
+	// If this is zero, workers' health is not checked.
+	HealthCheckInterval time.Duration
+
+	// HealthCheckFailures holds the number of consecutive health
+	// checks that a worker must fail before it is restarted, as if
+	// by RestartWorker. A check that takes longer than
+	// HealthCheckInterval fails. If this is zero, one failure is
+	// enough.
+	HealthCheckFailures int
+}
+
+// HealthChecker may be implemented by a worker started by a Runner
+// so that the runner can restart it if it becomes unhealthy
+// without exiting, for example because it has deadlocked.
+type HealthChecker interface {
+	// CheckHealth returns an error if the worker is not healthy.
+	// The context is cancelled if the check takes too long.
+	CheckHealth(ctx context.Context) error
+}
+
+// RestartMode describes when a Runner restarts a worker that has exited.
+type RestartMode int
+
+const (
+	// RestartOnError restarts the worker only when it exits with
+	// a non-fatal error; a worker that exits without error is
+	// removed from the runner. This is the default.
+	RestartOnError RestartMode = iota
+
+	// RestartAlways restarts the worker whenever it exits,
+	// even if it exited without error.
+	RestartAlways
+
+	// RestartNever never restarts the worker; it is removed
+	// from the runner as soon as it exits.
+	RestartNever
+)
+
+// RestartPolicy describes how a Runner restarts a particular worker.
+// The zero value restarts the worker on error, without limit,
+// after RunnerParams.RestartDelay.
+type RestartPolicy struct {
+	// Mode determines when the worker is restarted.
+	Mode RestartMode
+
+	// MaxRestarts holds the maximum number of times the worker
+	// will be restarted. If this is zero, there is no limit.
+	MaxRestarts int
+
+	// Delay holds the length of time the runner will wait
+	// before restarting the worker. If this is zero,
+	// RunnerParams.RestartDelay will be used.
+	Delay time.Duration
+
+	// FatalOnExhaustion causes the runner to treat a worker
+	// that has used up all of its MaxRestarts as a fatal error.
+	// If this is false, the worker is left in the "exhausted"
+	// state, and is not started again unless it is reset with
+	// ResetWorker.
+	FatalOnExhaustion bool
+}
+
+// StartOption configures how a Runner manages a worker
+// started with StartWorker.
+type StartOption func(*startOptions)
+
+// startOptions holds the values set by StartOptions.
+type startOptions struct {
+	policy RestartPolicy
+	stage  int
+	labels map[string]string
+	lease  Lease
+
+	isFatal       func(error) bool
+	moreImportant func(err0, err1 error) bool
+	filter        func(error) error
+
+	startAt    time.Time
+	startDelay time.Duration
+}
+
+// WithRestartPolicy returns a StartOption that sets the restart
+// policy for the worker.
+func WithRestartPolicy(policy RestartPolicy) StartOption {
+	return func(opts *startOptions) {
+		opts.policy = policy
+	}
+}
+
+// WithShutdownStage returns a StartOption that sets the stage in
+// which the worker is stopped when the runner shuts down. Workers
+// are stopped in order of increasing stage, and the runner waits for
+// all the workers in one stage to finish before stopping the next,
+// so a worker that others depend on should be given a higher
+// stage than its dependents. The default stage is zero.
+func WithShutdownStage(stage int) StartOption {
+	return func(opts *startOptions) {
+		opts.stage = stage
+	}
+}
+
+// WithLabels returns a StartOption that attaches the given labels
+// to the worker, so that it can be found with a Selector.
+func WithLabels(labels map[string]string) StartOption {
+	return func(opts *startOptions) {
+		opts.labels = make(map[string]string, len(labels))
+		for k, v := range labels {
+			opts.labels[k] = v
+		}
+	}
+}
+
+// WithLease returns a StartOption that makes the worker a singleton,
+// which only runs while the runner holds the given lease. The runner
+// claims the lease before starting the worker, kills the worker if
+// the lease is lost, and releases the lease when the worker exits.
+// A worker that is killed because its lease was lost is started again
+// when the lease has been claimed again, whatever its restart policy,
+// unless it returns an error of its own, which is handled as usual.
+func WithLease(lease Lease) StartOption {
+	return func(opts *startOptions) {
+		opts.lease = lease
+	}
+}
+
+// WithIsFatal returns a StartOption that sets the function used to
+// decide whether an error returned by the worker is fatal, in place
+// of RunnerParams.IsFatal.
+func WithIsFatal(isFatal func(error) bool) StartOption {
+	return func(opts *startOptions) {
+		opts.isFatal = isFatal
+	}
+}
+
+// WithMoreImportant returns a StartOption that sets the function
+// used to decide whether a fatal error returned by the worker is
+// more important than the runner's current most important error,
+// in place of RunnerParams.MoreImportant. The function of the worker
+// that returned the current error is consulted too: the new error
+// only replaces it if that function doesn't rank its own error higher.
+func WithMoreImportant(moreImportant func(err0, err1 error) bool) StartOption {
+	return func(opts *startOptions) {
+		opts.moreImportant = moreImportant
+	}
+}
+
+// WithFilter returns a StartOption that sets a function used to
+// convert the errors returned by the worker and its start function,
+// before they are checked with IsFatal. The function is also called
+// when the worker exits without an error, with a nil error.
+//
+// It's intended to convert domain-specific errors into errors such as
+// ErrRestartNow and ErrRemove, so that workers managed by a Runner
+// don't have to depend on this package directly. It's called from
+// the runner's goroutine, so it should not block.
+func WithFilter(filter func(error) error) StartOption {
+	return func(opts *startOptions) {
+		opts.filter = filter
+	}
+}
+
+// WithStartDelay returns a StartOption that delays the first start
+// of the worker by the given length of time, as measured by the
+// runner's clock. Until then, the worker is reported as "scheduled".
+// Restarting, replacing, pausing or resuming the worker before then
+// doesn't bring its start forward.
+func WithStartDelay(delay time.Duration) StartOption {
+	return func(opts *startOptions) {
+		opts.startAt = time.Time{}
+		opts.startDelay = delay
+	}
+}
+
+// WithStartAt returns a StartOption that delays the first start of
+// the worker until the given time, as measured by the runner's clock.
+// Until then, the worker is reported as "scheduled". If the time has
+// already passed, the worker is started straight away.
+func WithStartAt(t time.Time) StartOption {
+	return func(opts *startOptions) {
+		opts.startAt = t
+		opts.startDelay = 0
+	}
+}
+
+// Selector selects workers by their labels. A worker matches
+// a selector if it has all of the selector's labels, with the same
+// values. An empty selector matches all workers, but can only be
+// used to list them, not to stop them.
+type Selector map[string]string
+
+// Matches reports whether the given labels match the selector.
+func (s Selector) Matches(labels map[string]string) bool {
+	for k, v := range s {
+		if value, ok := labels[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+// StartFunc is a function that creates a worker,
+// as passed to StartWorker.
+type StartFunc func() (Worker, error)
+
+// SetWorkersResult describes the changes made by SetWorkers.
+// Each field holds worker ids in sorted order.
+type SetWorkersResult struct {
+	// Started holds the workers that were started.
+	Started []string
+
+	// Stopped holds the workers that were stopped.
+	Stopped []string
+
+	// Unchanged holds the workers that were already running.
+	Unchanged []string
+
+	// Stopping holds the workers that could not be started
+	// because they are still stopping after being stopped
+	// earlier. They can be started by calling SetWorkers
+	// again once they have been removed.
+	Stopping []string
+}
+
+// Validate returns an error if the params are not valid.
+func (p RunnerParams) Validate() error {
+	if p.BackoffFactor != 0 && p.BackoffFactor < 1 {
+		return errors.NotValidf("BackoffFactor %v less than 1", p.BackoffFactor)
+	}
+	if p.BackoffResetTime < 0 {
+		return errors.NotValidf("negative BackoffResetTime")
+	}
+	if p.MaxDelay < 0 {
+		return errors.NotValidf("negative MaxDelay")
+	}
+	return nil
 }
 
 // NewRunner creates a new Runner.  When a worker finishes, if its error
@@ -178,6 +803,9 @@ type RunnerParams struct {
 // The function isFatal(err) returns whether err is a fatal error.  The
 // function moreImportant(err0, err1) returns whether err0 is considered
 // more important than err1.
+//
+// If the params are not valid, the runner is returned already
+// killed, and Wait returns the validation error.
 func NewRunner(p RunnerParams) *Runner {
 	if p.IsFatal == nil {
 		p.IsFatal = func(error) bool {
@@ -198,16 +826,32 @@ func NewRunner(p RunnerParams) *Runner {
 	if p.Logger == nil {
 		p.Logger = noopLogger{}
 	}
+	if p.Metrics == nil {
+		p.Metrics = DefaultMetrics()
+	}
+	if p.HealthCheckFailures <= 0 {
+		p.HealthCheckFailures = 1
+	}
 
 	runner := &Runner{
 		startc:   make(chan startReq),
+		setc:     make(chan setReq),
 		stopc:    make(chan string),
+		stopSelc: make(chan Selector),
+		restartc: make(chan restartReq),
+		resetc:   make(chan restartReq),
+		pausec:   make(chan pauseReq),
+		queuec:   make(chan queueReq),
+		healthc:  make(chan healthInfo),
 		donec:    make(chan doneInfo),
 		startedc: make(chan startInfo),
 		params:   p,
 		workers:  make(map[string]*workerInfo),
 	}
 	runner.workersChangedCond.L = &runner.mu
+	if err := p.Validate(); err != nil {
+		runner.tomb.Kill(errors.Trace(err))
+	}
 	runner.tomb.Go(runner.run)
 	return runner
 }
@@ -215,16 +859,35 @@ func NewRunner(p RunnerParams) *Runner {
 // StartWorker starts a worker running associated with the given id.
 // The startFunc function will be called to create the worker;
 // when the worker exits, an AlreadyExists error will be returned.
+// Any options modify how the runner manages the worker.
 //
 // StartWorker returns ErrDead if the runner is not running.
-func (runner *Runner) StartWorker(id string, startFunc func() (Worker, error)) error {
+func (runner *Runner) StartWorker(id string, startFunc func() (Worker, error), options ...StartOption) error {
+	return runner.StartWorkerContext(id, func(context.Context) (Worker, error) {
+		return startFunc()
+	}, options...)
+}
+
+// StartWorkerContext is like StartWorker except that the startFunc
+// function is passed a context. The context is cancelled when the
+// worker is stopped, or when the worker exits. If the runner starts
+// shutting down while startFunc is running, the context is cancelled
+// straight away, whatever the worker's shutdown stage. It holds the worker id and the number of the attempt
+// to start it, which can be retrieved with WorkerIDFromContext and
+// AttemptFromContext. An error returned by startFunc after the runner
+// has cancelled the context is ignored.
+func (runner *Runner) StartWorkerContext(id string, startFunc func(context.Context) (Worker, error), options ...StartOption) error {
+	var opts startOptions
+	for _, option := range options {
+		option(&opts)
+	}
 	// Note: we need the reply channel so that when StartWorker
 	// returns, we're guaranteed that the worker is installed
 	// when we return, so Worker will see it if called
 	// immediately afterwards.
 	reply := make(chan error)
 	select {
-	case runner.startc <- startReq{id, startFunc, reply}:
+	case runner.startc <- startReq{id: id, start: startFunc, opts: opts, reply: reply}:
 		// We're certain to get a reply because the startc channel is synchronous
 		// so if we succeed in sending on it, we know that the run goroutine has entered
 		// the startc arm of the select, and that calls startWorker (which never blocks)
@@ -235,7 +898,67 @@ func (runner *Runner) StartWorker(id string, startFunc func() (Worker, error)) e
 	return ErrDead
 }
 
+// AddWorker adds an existing, running worker to the runner, which
+// takes over responsibility for it, associating it with the given id.
+// The worker is handled like a started worker, except that it is never
+// restarted; when it exits it is removed from the runner, having had
+// its error checked with IsFatal. RestartWorker and PauseWorker
+// return a NotSupported error for it, but it may be replaced with
+// a restartable worker by ReplaceWorker. Restart policies, leases
+// and start delays given as options don't apply, and its health is
+// not checked. If the runner is dying, the worker is killed
+// straight away.
+//
+// If AddWorker returns an error, the caller remains responsible
+// for the worker. It returns an AlreadyExists error if there
+// is already a worker with the id, and ErrDead if the runner
+// is not running.
+func (runner *Runner) AddWorker(id string, w Worker, options ...StartOption) error {
+	var opts startOptions
+	for _, option := range options {
+		option(&opts)
+	}
+	reply := make(chan error)
+	select {
+	case runner.startc <- startReq{id: id, adopt: w, opts: opts, reply: reply}:
+		return <-reply
+	case <-runner.tomb.Dead():
+	}
+	return ErrDead
+}
+
+// SetWorkers makes the runner's set of workers match the given
+// desired set. Any desired worker that isn't known to the runner is
+// started with the given start function and options; any worker that
+// isn't desired is stopped, as if by StopWorker. Workers that are both
+// known and desired are left alone, even if their start functions
+// differ. The changes are made together inside the runner, and a
+// summary of them is returned.
+//
+// SetWorkers returns ErrDead if the runner is not running.
+func (runner *Runner) SetWorkers(desired map[string]StartFunc, options ...StartOption) (SetWorkersResult, error) {
+	var opts startOptions
+	for _, option := range options {
+		option(&opts)
+	}
+	starts := make(map[string]func(context.Context) (Worker, error), len(desired))
+	for id, start := range desired {
+		start := start
+		starts[id] = func(context.Context) (Worker, error) {
+			return start()
+		}
+	}
+	reply := make(chan SetWorkersResult)
+	select {
+	case runner.setc <- setReq{starts, opts, reply}:
+		return <-reply, nil
+	case <-runner.tomb.Dead():
+	}
+	return SetWorkersResult{}, ErrDead
+}
+
 // StopWorker stops the worker associated with the given id.
+// Any pending restart of the worker is cancelled.
 // It does nothing if there is no such worker.
 //
 // StopWorker returns ErrDead if the runner is not running.
@@ -248,85 +971,226 @@ func (runner *Runner) StopWorker(id string) error {
 	return ErrDead
 }
 
-// StopAndRemoveWorker stops the worker and returns any error reported by
-// the worker, waiting for the worker to be no longer known to the runner.
-// If it was stopped while waiting, StopAndRemoveWorker will return ErrAborted.
+// StopWorkers stops all the workers whose labels match the selector,
+// as if by StopWorker. The selector must not be empty, so that all
+// the workers can't be stopped by mistake.
 //
-// StopAndRemoveWorker returns ErrDead if the runner is not running.
-func (runner *Runner) StopAndRemoveWorker(id string, abort <-chan struct{}) error {
-	w, done, err := runner.workerInfo(id, abort)
-	if err != nil {
-		return err
+// StopWorkers returns ErrDead if the runner is not running.
+func (runner *Runner) StopWorkers(selector Selector) error {
+	if len(selector) == 0 {
+		return errors.NotValidf("empty selector")
 	}
-	workerErr := Stop(w)
-
 	select {
-	case <-abort:
-	case <-done:
-		return workerErr
+	case runner.stopSelc <- selector:
+		return nil
+	case <-runner.tomb.Dead():
 	}
-	return ErrAborted
+	return ErrDead
 }
 
-// Wait implements Worker.Wait
-func (runner *Runner) Wait() error {
-	return runner.tomb.Wait()
+// Workers returns the ids of the current workers whose labels
+// match the selector, in sorted order.
+func (runner *Runner) Workers(selector Selector) []string {
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+	var ids []string
+	for id, info := range runner.workers {
+		if selector.Matches(info.labels) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
 }
 
-// Kill implements Worker.Kill
-func (runner *Runner) Kill() {
-	runner.params.Logger.Debugf("killing runner %p", runner)
-	runner.tomb.Kill(nil)
+// RestartWorker stops the worker associated with the given id and
+// starts it again with the same start function, without waiting for
+// the restart delay. The worker's restart policy doesn't apply, and
+// the restart doesn't count towards its maximum number of restarts.
+// An error that the stopped worker returns is still checked with
+// IsFatal.
+//
+// RestartWorker returns a NotFound error if there is no such worker,
+// and ErrDead if the runner is not running.
+func (runner *Runner) RestartWorker(id string) error {
+	reply := make(chan error)
+	select {
+	case runner.restartc <- restartReq{id, nil, reply}:
+		return <-reply
+	case <-runner.tomb.Dead():
+	}
+	return ErrDead
 }
 
-// Worker returns the current worker for the given id.
-// If a worker has been started with the given id but is
-// not currently available, it will wait until it is available,
-// stopping waiting if it receives a value on the stop channel.
+// ReplaceWorker replaces the worker associated with the given id with
+// one created by calling startFunc. The current worker, if any, is
+// stopped, and the new one started as soon as it exits, without waiting
+// for the restart delay. The worker keeps its options; its restart
+// count and backoff are reset. While the worker is being replaced,
+// Worker waits for the new worker rather than returning a NotFound
+// error. If there is no such worker, ReplaceWorker starts one, as if
+// by StartWorker.
 //
-// If there is no worker started with the given id, Worker
-// will return ErrNotFound. If it was aborted while
-// waiting, Worker will return ErrAborted. If the runner
-// has been killed while waiting, Worker will return ErrDead.
-func (runner *Runner) Worker(id string, abort <-chan struct{}) (Worker, error) {
-	w, _, err := runner.workerInfo(id, abort)
-	return w, err
+// ReplaceWorker returns an error if the worker is being stopped,
+// and ErrDead if the runner is not running.
+func (runner *Runner) ReplaceWorker(id string, startFunc func() (Worker, error)) error {
+	return runner.ReplaceWorkerContext(id, func(context.Context) (Worker, error) {
+		return startFunc()
+	})
 }
 
-func (runner *Runner) workerInfo(id string, abort <-chan struct{}) (Worker, <-chan struct{}, error) {
-	runner.mu.Lock()
-	// getWorker returns the current worker for the id
-	// and reports an ErrNotFound error if the worker
-	// isn't found.
-	getWorker := func() (Worker, <-chan struct{}, error) {
-		info := runner.workers[id]
-		if info == nil {
-			// No entry for the id means the worker
-			// will never become available.
-			return nil, nil, errors.NotFoundf("worker %q", id)
-		}
-		return info.worker, info.done, nil
+// ReplaceWorkerContext is like ReplaceWorker except that the startFunc
+// function is passed a context, as with StartWorkerContext.
+func (runner *Runner) ReplaceWorkerContext(id string, startFunc func(context.Context) (Worker, error)) error {
+	reply := make(chan error)
+	select {
+	case runner.restartc <- restartReq{id, startFunc, reply}:
+		return <-reply
+	case <-runner.tomb.Dead():
 	}
-	if w, done, err := getWorker(); err != nil || w != nil {
-		// The worker is immediately available  (or we know it's
-		// not going to become available). No need
-		// to block waiting for it.
-		runner.mu.Unlock()
-		// If it wasn't found, it's possible that's because
-		// the whole thing has shut down, so
-		// check for dying so that we don't mislead
-		// our caller.
-		select {
-		case <-runner.tomb.Dying():
-			return nil, nil, ErrDead
-		default:
-		}
-		return w, done, err
+	return ErrDead
+}
+
+// ResetWorker resets the count of recent failures of the worker
+// associated with the given id. If the worker has failed too often
+// and is not being restarted, it is started again straight away.
+// If it has used up all of its restarts, its restart count is reset
+// and it is started again in the same way.
+//
+// ResetWorker returns a NotFound error if there is no such worker,
+// and ErrDead if the runner is not running.
+func (runner *Runner) ResetWorker(id string) error {
+	reply := make(chan error)
+	select {
+	case runner.resetc <- restartReq{id: id, reply: reply}:
+		return <-reply
+	case <-runner.tomb.Dead():
+	}
+	return ErrDead
+}
+
+// PauseWorker stops the worker associated with the given id, or
+// cancels any pending restart, without forgetting it. The worker is
+// not started again until it is resumed with ResumeWorker. Replacing
+// a paused worker with ReplaceWorker changes the worker that will be
+// started when it is resumed.
+//
+// PauseWorker returns a NotFound error if there is no such worker,
+// and ErrDead if the runner is not running.
+func (runner *Runner) PauseWorker(id string) error {
+	return runner.sendPause(id, true)
+}
+
+// ResumeWorker starts the paused worker associated with the given
+// id again, without waiting for the restart delay. It does nothing
+// if the worker is not paused.
+//
+// ResumeWorker returns a NotFound error if there is no such worker,
+// and ErrDead if the runner is not running.
+func (runner *Runner) ResumeWorker(id string) error {
+	return runner.sendPause(id, false)
+}
+
+func (runner *Runner) sendPause(id string, pause bool) error {
+	reply := make(chan error)
+	select {
+	case runner.pausec <- pauseReq{id, pause, reply}:
+		return <-reply
+	case <-runner.tomb.Dead():
+	}
+	return ErrDead
+}
+
+// StopAndRemoveWorker stops the worker and returns any error reported by
+// the worker, waiting for the worker to be no longer known to the runner.
+// A worker that is waiting to be restarted is removed without being
+// started again.
+// If it was stopped while waiting, StopAndRemoveWorker will return ErrAborted.
+//
+// StopAndRemoveWorker returns ErrDead if the runner is not running.
+func (runner *Runner) StopAndRemoveWorker(id string, abort <-chan struct{}) error {
+	runner.mu.Lock()
+	info := runner.workers[id]
+	runner.mu.Unlock()
+	if info == nil {
+		// If it wasn't found, it's possible that's because
+		// the whole thing has shut down, so
+		// check for dying so that we don't mislead
+		// our caller.
+		select {
+		case <-runner.tomb.Dying():
+			return ErrDead
+		default:
+		}
+		return errors.NotFoundf("worker %q", id)
+	}
+	if err := runner.StopWorker(id); err != nil {
+		return err
+	}
+	select {
+	case <-abort:
+	case err := <-info.done:
+		return err
+	case <-runner.tomb.Dead():
+		// The runner has abandoned the worker.
+		return ErrDead
+	}
+	return ErrAborted
+}
+
+// Wait implements Worker.Wait
+func (runner *Runner) Wait() error {
+	return runner.tomb.Wait()
+}
+
+// Kill implements Worker.Kill
+func (runner *Runner) Kill() {
+	runner.params.Logger.Debugf("killing runner %p", runner)
+	runner.tomb.Kill(nil)
+}
+
+// Worker returns the current worker for the given id.
+// If a worker has been started with the given id but is
+// not currently available, it will wait until it is available,
+// stopping waiting if it receives a value on the stop channel.
+//
+// If there is no worker started with the given id, Worker
+// will return ErrNotFound. If it was aborted while
+// waiting, Worker will return ErrAborted. If the runner
+// has been killed while waiting, Worker will return ErrDead.
+func (runner *Runner) Worker(id string, abort <-chan struct{}) (Worker, error) {
+	runner.mu.Lock()
+	// getWorker returns the current worker for the id
+	// and reports an ErrNotFound error if the worker
+	// isn't found.
+	getWorker := func() (Worker, error) {
+		info := runner.workers[id]
+		if info == nil {
+			// No entry for the id means the worker
+			// will never become available.
+			return nil, errors.NotFoundf("worker %q", id)
+		}
+		return info.worker, nil
+	}
+	if w, err := getWorker(); err != nil || w != nil {
+		// The worker is immediately available  (or we know it's
+		// not going to become available). No need
+		// to block waiting for it.
+		runner.mu.Unlock()
+		// If it wasn't found, it's possible that's because
+		// the whole thing has shut down, so
+		// check for dying so that we don't mislead
+		// our caller.
+		select {
+		case <-runner.tomb.Dying():
+			return nil, ErrDead
+		default:
+		}
+		return w, err
 	}
 	type workerResult struct {
-		w    Worker
-		done <-chan struct{}
-		err  error
+		w   Worker
+		err error
 	}
 	wc := make(chan workerResult, 1)
 	stopped := make(chan struct{})
@@ -340,8 +1204,8 @@ func (runner *Runner) workerInfo(id string, abort <-chan struct{}) (Worker, <-ch
 				// Note: sync.Condition.Wait unlocks the mutex before
 				// waiting, then locks it again before returning.
 				runner.workersChangedCond.Wait()
-				if w, done, err := getWorker(); err != nil || w != nil {
-					wc <- workerResult{w, done, err}
+				if w, err := getWorker(); err != nil || w != nil {
+					wc <- workerResult{w, err}
 					return
 				}
 			}
@@ -356,13 +1220,13 @@ func (runner *Runner) workerInfo(id string, abort <-chan struct{}) (Worker, <-ch
 			// our caller.
 			select {
 			case <-runner.tomb.Dying():
-				return nil, nil, ErrDead
+				return nil, ErrDead
 			default:
 			}
 		}
-		return w.w, w.done, w.err
+		return w.w, w.err
 	case <-runner.tomb.Dying():
-		return nil, nil, ErrDead
+		return nil, ErrDead
 	case <-abort:
 	}
 	// Stop our wait goroutine.
@@ -372,14 +1236,19 @@ func (runner *Runner) workerInfo(id string, abort <-chan struct{}) (Worker, <-ch
 	// goroutine.
 	close(stopped)
 	runner.workersChangedCond.Broadcast()
-	return nil, nil, ErrAborted
+	return nil, ErrAborted
 }
 
 func (runner *Runner) run() error {
+	defer runner.closeSubscriptions()
 	tombDying := runner.tomb.Dying()
+	var stopTimeout <-chan time.Time
 	for {
 		if runner.isDying && len(runner.workers) == 0 {
-			return runner.finalError
+			return runner.exitError()
+		}
+		if runner.isDying && stopTimeout == nil && runner.params.StopTimeout > 0 {
+			stopTimeout = runner.params.Clock.After(runner.params.StopTimeout)
 		}
 		select {
 		case <-tombDying:
@@ -392,45 +1261,323 @@ func (runner *Runner) run() error {
 			runner.params.Logger.Debugf("start %q", req.id)
 			req.reply <- runner.startWorker(req)
 
+		case req := <-runner.setc:
+			runner.params.Logger.Debugf("set %d workers", len(req.desired))
+			req.reply <- runner.setWorkers(req)
+
 		case id := <-runner.stopc:
 			runner.params.Logger.Debugf("stop %q", id)
 			runner.killWorker(id)
 
+		case selector := <-runner.stopSelc:
+			runner.params.Logger.Debugf("stop workers matching %v", selector)
+			runner.killWorkers(selector)
+
+		case req := <-runner.restartc:
+			if req.start != nil && runner.workers[req.id] == nil {
+				runner.params.Logger.Debugf("replace %q: not found, starting", req.id)
+				req.reply <- runner.startWorker(startReq{id: req.id, start: req.start})
+				break
+			}
+			runner.params.Logger.Debugf("restart %q", req.id)
+			req.reply <- runner.bounceWorker(req.id, req.start)
+
+		case req := <-runner.resetc:
+			runner.params.Logger.Debugf("reset %q", req.id)
+			req.reply <- runner.resetWorker(req.id)
+
+		case req := <-runner.pausec:
+			if req.pause {
+				runner.params.Logger.Debugf("pause %q", req.id)
+				req.reply <- runner.pauseWorker(req.id)
+			} else {
+				runner.params.Logger.Debugf("resume %q", req.id)
+				req.reply <- runner.resumeWorker(req.id)
+			}
+
+		case req := <-runner.queuec:
+			runner.params.Logger.Debugf("%q queued to start", req.id)
+			runner.queueStart(req)
+
+		case info := <-runner.healthc:
+			runner.healthChecked(info)
+
 		case info := <-runner.startedc:
 			runner.params.Logger.Debugf("%q started", info.id)
-			runner.setWorker(info.id, info.worker)
-			if runner.notifyStarted != nil {
-				runner.notifyStarted <- info.worker
-			}
+			runner.releaseStart(info.id)
+			runner.workerStarted(info.id, info.worker)
+
 		case info := <-runner.donec:
 			runner.params.Logger.Debugf("%q done: %v", info.id, info.err)
+			runner.releaseStart(info.id)
 			runner.workerDone(info)
+			if runner.isDying {
+				// Move on to the next shutdown stage
+				// if this was the last worker in its stage.
+				runner.killAll()
+			}
+
+		case <-stopTimeout:
+			stuck := runner.stuckWorkers()
+			if runner.params.AbandonStuckWorkers {
+				runner.abandonAll()
+				return &StuckWorkersError{
+					IDs:     stuck,
+					Timeout: runner.params.StopTimeout,
+					Err:     runner.exitError(),
+				}
+			}
+			stopTimeout = nil
 		}
 		runner.workersChangedCond.Broadcast()
 	}
 }
 
+// queueStart adds the worker to the queue of
+// workers waiting to start.
+func (runner *Runner) queueStart(req queueReq) {
+	info := runner.workers[req.id]
+	runner.mu.Lock()
+	info.queued = true
+	info.grant = req.grant
+	runner.mu.Unlock()
+	runner.startQueue = append(runner.startQueue, req.id)
+	runner.grantStarts()
+}
+
+// queueReady queues the given worker, which is about to be started
+// after the given delay, straight away if it will be ready to start
+// as soon as its runWorker goroutine runs, so that workers are started
+// in the order they are queued here. It returns the channel that will
+// be closed when the worker may start, or nil if it isn't queued.
+// Workers that must wait for a delay or a lease first queue
+// themselves when they are ready.
+func (runner *Runner) queueReady(id string, info *workerInfo, delay time.Duration) chan struct{} {
+	if runner.params.MaxConcurrentStarts <= 0 || delay > 0 || info.lease != nil {
+		return nil
+	}
+	grant := make(chan struct{})
+	runner.params.Logger.Debugf("%q queued to start", id)
+	runner.queueStart(queueReq{id, grant})
+	return grant
+}
+
+// releaseStart is called when the start function of the worker
+// with the given id has returned, or it has given up waiting to
+// start. It frees its place for the next queued worker.
+func (runner *Runner) releaseStart(id string) {
+	info := runner.workers[id]
+	if info.holdsSlot {
+		info.holdsSlot = false
+		runner.starting--
+	}
+	if info.queued {
+		for i, queuedID := range runner.startQueue {
+			if queuedID == id {
+				runner.startQueue = append(runner.startQueue[:i], runner.startQueue[i+1:]...)
+				break
+			}
+		}
+		runner.mu.Lock()
+		info.queued = false
+		info.grant = nil
+		runner.mu.Unlock()
+	}
+	runner.grantStarts()
+}
+
+// grantStarts lets as many queued workers start
+// as RunnerParams.MaxConcurrentStarts allows.
+func (runner *Runner) grantStarts() {
+	for len(runner.startQueue) > 0 && runner.starting < runner.params.MaxConcurrentStarts {
+		id := runner.startQueue[0]
+		runner.startQueue = runner.startQueue[1:]
+		info := runner.workers[id]
+		runner.mu.Lock()
+		info.queued = false
+		runner.mu.Unlock()
+		close(info.grant)
+		info.grant = nil
+		info.holdsSlot = true
+		runner.starting++
+	}
+}
+
+// healthChecked responds when the health of a worker has been
+// checked, restarting the worker if it has failed too many checks.
+func (runner *Runner) healthChecked(health healthInfo) {
+	info := runner.workers[health.id]
+	if info == nil || info.attempts != health.attempt || info.worker == nil {
+		// The worker has exited since it was checked.
+		return
+	}
+	runner.mu.Lock()
+	info.health = health.err
+	info.healthChecked = true
+	if health.err == nil {
+		info.healthFailures = 0
+	} else {
+		info.healthFailures++
+	}
+	runner.mu.Unlock()
+	if health.err == nil {
+		return
+	}
+	runner.params.Logger.Errorf("%q failed health check: %v", health.id, health.err)
+	if info.healthFailures >= runner.params.HealthCheckFailures {
+		runner.params.Logger.Infof("restarting unhealthy worker %q", health.id)
+		if err := runner.bounceWorker(health.id, nil); err != nil {
+			runner.params.Logger.Errorf("cannot restart %q: %v", health.id, err)
+		}
+	}
+}
+
+// exitError returns the error that the runner
+// should exit with.
+func (runner *Runner) exitError() error {
+	if runner.finalError == nil || !runner.params.AggregateFatalErrors {
+		return runner.finalError
+	}
+	return &FatalErrors{
+		Errors: runner.fatalErrors,
+		Err:    runner.finalError,
+	}
+}
+
+// stuckWorkers logs the workers that are still stopping
+// and returns their ids.
+func (runner *Runner) stuckWorkers() []string {
+	now := runner.params.Clock.Now().UTC()
+	var ids []string
+	for id, info := range runner.workers {
+		if info.stopping {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		runner.params.Logger.Errorf("%q still stopping after %v", id, now.Sub(runner.workers[id].stopRequested))
+	}
+	return ids
+}
+
+// abandonAll kills all the remaining workers, whatever their
+// shutdown stage, so that they don't outlive the runner.
+func (runner *Runner) abandonAll() {
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+	for id, info := range runner.workers {
+		if !info.stopping {
+			runner.killWorkerLocked(id)
+		}
+	}
+}
+
 // startWorker responds when a worker has been started
 // by calling StartWorker.
 func (runner *Runner) startWorker(req startReq) error {
 	if runner.isDying {
 		runner.params.Logger.Infof("ignoring start request for %q when dying", req.id)
+		if req.adopt != nil {
+			req.adopt.Kill()
+		}
 		return nil
 	}
-	info := runner.workers[req.id]
-	if info == nil {
-		runner.mu.Lock()
-		defer runner.mu.Unlock()
-		runner.workers[req.id] = &workerInfo{
-			start:        req.start,
-			restartDelay: runner.params.RestartDelay,
-			started:      runner.params.Clock.Now().UTC(),
-			done:         make(chan struct{}, 1),
+	if runner.workers[req.id] != nil {
+		return errors.AlreadyExistsf("worker %q", req.id)
+	}
+	restartDelay := req.opts.policy.Delay
+	if restartDelay == 0 {
+		restartDelay = runner.params.RestartDelay
+	}
+	info := &workerInfo{
+		start:        req.start,
+		restartDelay: restartDelay,
+		done:         make(chan error, 1),
+		policy:       req.opts.policy,
+		stage:        req.opts.stage,
+		labels:       req.opts.labels,
+		lease:        req.opts.lease,
+
+		isFatal:       req.opts.isFatal,
+		moreImportant: req.opts.moreImportant,
+		filter:        req.opts.filter,
+	}
+	if req.adopt != nil {
+		info.lease = nil
+	}
+	var delay time.Duration
+	if req.adopt == nil {
+		now := runner.params.Clock.Now().UTC()
+		delay = req.opts.startDelay
+		if !req.opts.startAt.IsZero() {
+			delay = req.opts.startAt.Sub(now)
+		}
+		if delay > 0 {
+			info.scheduled = true
+			info.nextStart = now.Add(delay)
 		}
-		go runner.runWorker(0, req.id, req.start)
+	}
+	runner.mu.Lock()
+	runner.workers[req.id] = info
+	runner.mu.Unlock()
+	ctx := info.newContext(req.id)
+	if req.adopt != nil {
+		// The worker is already running, so there's
+		// nothing to wait for.
+		runner.workerStarted(req.id, req.adopt)
+		go runner.waitAdopted(req.id, req.adopt)
 		return nil
 	}
-	return errors.AlreadyExistsf("worker %q", req.id)
+	grant := runner.queueReady(req.id, info, delay)
+	go runner.runWorker(ctx, delay, req.id, req.start, info.lease, grant)
+	return nil
+}
+
+// workerStarted responds when the worker with the
+// given id has been started.
+func (runner *Runner) workerStarted(id string, w Worker) {
+	runner.setWorker(id, w)
+	runner.params.Metrics.RecordStart(id)
+	runner.publish(Event{Kind: EventStarted, ID: id})
+	if runner.notifyStarted != nil {
+		runner.notifyStarted <- w
+	}
+}
+
+// setWorkers responds when the set of workers is set by calling
+// SetWorkers.
+func (runner *Runner) setWorkers(req setReq) SetWorkersResult {
+	var result SetWorkersResult
+	if runner.isDying {
+		runner.params.Logger.Infof("ignoring set workers request when dying")
+		return result
+	}
+	for id, start := range req.desired {
+		info := runner.workers[id]
+		switch {
+		case info == nil:
+			// This can't fail, as the worker doesn't exist
+			// and the runner isn't dying.
+			_ = runner.startWorker(startReq{id: id, start: start, opts: req.opts})
+			result.Started = append(result.Started, id)
+		case info.stopping:
+			result.Stopping = append(result.Stopping, id)
+		default:
+			result.Unchanged = append(result.Unchanged, id)
+		}
+	}
+	for id, info := range runner.workers {
+		if _, ok := req.desired[id]; !ok && !info.stopping {
+			runner.killWorker(id)
+			result.Stopped = append(result.Stopped, id)
+		}
+	}
+	sort.Strings(result.Started)
+	sort.Strings(result.Stopped)
+	sort.Strings(result.Unchanged)
+	sort.Strings(result.Stopping)
+	return result
 }
 
 type panicError interface {
@@ -439,14 +1586,77 @@ type panicError interface {
 	Panicked() bool
 }
 
+// errWithStackTrace holds the runtime stack associated with an error
+// recovered from a panic.
+type errWithStackTrace struct {
+	error
+	stackTrace string
+}
+
+// StackTrace implements panicError.
+func (e *errWithStackTrace) StackTrace() []string {
+	return strings.Split(strings.TrimSpace(e.stackTrace), "\n")
+}
+
+// Panicked implements panicError.
+func (e *errWithStackTrace) Panicked() bool {
+	return true
+}
+
+// newPanicError returns an error holding the given
+// value recovered from a panic, and the current stack.
+func newPanicError(v interface{}) error {
+	return &errWithStackTrace{
+		error:      errors.Errorf("panic resulted in: %v", v),
+		stackTrace: string(debug.Stack()),
+	}
+}
+
 // workerDone responds when a worker has finished or failed
 // to start. It maintains the runner.finalError field and
 // restarts the worker if necessary.
 func (runner *Runner) workerDone(info doneInfo) {
 	workerInfo := runner.workers[info.id]
-	if !workerInfo.stopping && info.err == nil {
+	workerInfo.cancel()
+	leaseLost := info.leaseLost
+	if workerInfo.filter != nil && !leaseLost {
+		info.err = workerInfo.filter(info.err)
+	}
+	isFatal := runner.params.IsFatal
+	if workerInfo.isFatal != nil {
+		isFatal = workerInfo.isFatal
+	}
+	fatal := info.err != nil && !leaseLost && isFatal(info.err)
+	// A worker that asks to be restarted or removed
+	// hasn't failed, unless IsFatal says otherwise.
+	sentinel := !fatal && isRestartSentinel(info.err)
+	if !workerInfo.restarting && !workerInfo.paused && !leaseLost && !sentinel {
+		runner.updateRecentErrors(workerInfo, info.err)
+	}
+	if info.attempted {
+		runner.recordExit(info.id, workerInfo, info.err, fatal)
+	}
+	runner.mu.Lock()
+	wasRunning := workerInfo.running
+	workerInfo.running = false
+	if info.attempted {
+		// A worker that hasn't been started yet
+		// keeps its scheduled start time.
+		workerInfo.scheduled = false
+	}
+	if info.err != nil {
+		workerInfo.err = info.err
+		workerInfo.errTime = runner.params.Clock.Now().UTC()
+	}
+	runner.mu.Unlock()
+	if info.err == nil && (info.attempted || wasRunning) {
+		// Only a worker that was started, or was being
+		// started, has stopped; a cancelled wait hasn't.
+		runner.publish(Event{Kind: EventStopped, ID: info.id})
+	}
+	if !workerInfo.stopping && !workerInfo.restarting && !workerInfo.paused && info.err == nil && workerInfo.policy.Mode != RestartAlways {
 		runner.params.Logger.Debugf("removing %q from known workers", info.id)
-		runner.removeWorker(info.id, workerInfo.done)
+		runner.removeWorker(info.id, info.err)
 		return
 	}
 	if info.err != nil {
@@ -455,40 +1665,386 @@ func (runner *Runner) workerDone(info doneInfo) {
 			// Panics should always have the full stacktrace in the error log.
 			errStr = strings.Join(append([]string{errStr}, errWithStack.StackTrace()...), "\n")
 		}
-		if runner.params.IsFatal(info.err) {
+		if fatal {
 			runner.params.Logger.Errorf("fatal %q: %s", info.id, errStr)
-			if runner.finalError == nil || runner.params.MoreImportant(info.err, runner.finalError) {
-				runner.finalError = info.err
-			}
-			runner.removeWorker(info.id, workerInfo.done)
-			if !runner.isDying {
-				runner.isDying = true
-				runner.killAll()
-			}
+			runner.setFatal(info.id, info.err)
 			return
 		}
-		runner.params.Logger.Errorf("exited %q: %s", info.id, errStr)
+		if sentinel {
+			runner.params.Logger.Infof("exited %q: %s", info.id, errStr)
+		} else {
+			runner.params.Logger.Errorf("exited %q: %s", info.id, errStr)
+			runner.publish(Event{Kind: EventError, ID: info.id, Err: info.err})
+		}
 	}
 	if workerInfo.start == nil {
 		runner.params.Logger.Debugf("no restart, removing %q from known workers", info.id)
 
 		// The worker has been deliberately stopped;
 		// we can now remove it from the list of workers.
-		runner.removeWorker(info.id, workerInfo.done)
+		runner.removeWorker(info.id, info.err)
+		return
+	}
+	if runner.isDying {
+		runner.params.Logger.Debugf("runner is dying, removing %q from known workers", info.id)
+		runner.removeWorker(info.id, info.err)
+		return
+	}
+	if workerInfo.paused {
+		runner.params.Logger.Debugf("%q is paused, not restarting", info.id)
+		runner.parkWorker(info.id, workerInfo)
 		return
 	}
-	go runner.runWorker(workerInfo.restartDelay, info.id, workerInfo.start)
-	workerInfo.restartDelay = runner.params.RestartDelay
+	if sentinel && errors.Cause(info.err) == ErrRemove {
+		runner.params.Logger.Debugf("%q asked to be removed from known workers", info.id)
+		runner.removeWorker(info.id, info.err)
+		return
+	}
+	if workerInfo.restarting || leaseLost {
+		// The worker will wait to claim its
+		// lease again, if it's lost it.
+		workerInfo.restarting = false
+		var delay time.Duration
+		if workerInfo.scheduled {
+			delay = workerInfo.nextStart.Sub(runner.params.Clock.Now().UTC())
+		}
+		runner.restartWorker(info.id, workerInfo, delay)
+		return
+	}
+	if sentinel {
+		// The worker has asked to be restarted, so its
+		// restart policy and restart limit don't apply.
+		var delay time.Duration
+		if err, ok := errors.Cause(info.err).(*restartAfterError); ok {
+			delay = err.delay
+		}
+		runner.restartWorker(info.id, workerInfo, delay)
+		return
+	}
+	if workerInfo.policy.Mode == RestartNever {
+		runner.params.Logger.Debugf("restart policy forbids restart, removing %q from known workers", info.id)
+		runner.removeWorker(info.id, info.err)
+		return
+	}
+	if info.err != nil && runner.addFailure(workerInfo) {
+		runner.params.Logger.Errorf("%q failed %d times, not restarting until reset", info.id, len(workerInfo.failures))
+		runner.mu.Lock()
+		workerInfo.failed = true
+		runner.mu.Unlock()
+		runner.publish(Event{Kind: EventFailed, ID: info.id, Err: info.err})
+		runner.parkWorker(info.id, workerInfo)
+		return
+	}
+	if max := workerInfo.policy.MaxRestarts; max > 0 && workerInfo.restarts >= max {
+		if workerInfo.policy.FatalOnExhaustion {
+			err := info.err
+			if err == nil {
+				err = errors.Errorf("worker %q exhausted %d restarts", info.id, max)
+			}
+			runner.params.Logger.Errorf("fatal %q: restart limit %d reached", info.id, max)
+			runner.setFatal(info.id, err)
+			return
+		}
+		runner.params.Logger.Infof("restart limit %d reached, not restarting %q until reset", max, info.id)
+		runner.mu.Lock()
+		workerInfo.exhausted = true
+		runner.mu.Unlock()
+		runner.params.Metrics.RecordExhausted(info.id)
+		runner.publish(Event{Kind: EventExhausted, ID: info.id, Err: info.err})
+		runner.parkWorker(info.id, workerInfo)
+		return
+	}
+	runner.mu.Lock()
+	workerInfo.restarts++
+	runner.mu.Unlock()
+	runner.restartWorker(info.id, workerInfo, runner.restartDelay(workerInfo))
+}
+
+// restartWorker starts the given worker, which has exited,
+// again after the given delay.
+func (runner *Runner) restartWorker(id string, info *workerInfo, delay time.Duration) {
+	runner.mu.Lock()
+	info.worker = nil
+	info.failed = false
+	info.exhausted = false
+	info.nextStart = runner.params.Clock.Now().UTC().Add(delay)
+	runner.mu.Unlock()
+	runner.params.Metrics.RecordRestart(id)
+	runner.publish(Event{Kind: EventRestartScheduled, ID: id, Delay: delay})
+	ctx := info.newContext(id)
+	grant := runner.queueReady(id, info, delay)
+	go runner.runWorker(ctx, delay, id, info.start, info.lease, grant)
+}
+
+// bounceWorker responds when a worker is restarted by calling
+// RestartWorker or ReplaceWorker. It stops the current worker, if any;
+// workerDone will start it again when it exits. If start is not nil,
+// it replaces the worker's start function.
+func (runner *Runner) bounceWorker(id string, start func(context.Context) (Worker, error)) error {
+	info := runner.workers[id]
+	if info == nil {
+		return errors.NotFoundf("worker %q", id)
+	}
+	if info.stopping {
+		return errors.Errorf("worker %q is stopping", id)
+	}
+	if info.paused && start == nil {
+		return errors.Errorf("worker %q is paused", id)
+	}
+	if info.start == nil && start == nil {
+		return errors.NotSupportedf("restarting added worker %q", id)
+	}
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+	if start != nil {
+		// The replacement is a new worker as far
+		// as restarts and backoff are concerned.
+		info.start = start
+		info.restarts = 0
+		info.recentErrors = 0
+	}
+	if info.paused {
+		// The replacement will be started when
+		// the worker is resumed.
+		return nil
+	}
+	info.restarting = true
+	// Interrupt any pending restart or start.
+	info.cancel()
+	if info.worker != nil {
+		runner.params.Logger.Debugf("killing %q for restart", id)
+		info.worker.Kill()
+		info.worker = nil
+	}
+	return nil
+}
+
+// addFailure records a failure of the given worker, and reports
+// whether it has now failed too often to be restarted.
+func (runner *Runner) addFailure(info *workerInfo) bool {
+	threshold := runner.params.FailureThreshold
+	if threshold <= 0 {
+		return false
+	}
+	now := runner.params.Clock.Now().UTC()
+	failures := info.failures[:0]
+	for _, t := range info.failures {
+		if runner.params.FailureWindow == 0 || now.Sub(t) < runner.params.FailureWindow {
+			failures = append(failures, t)
+		}
+	}
+	info.failures = append(failures, now)
+	return len(info.failures) >= threshold
+}
+
+// parkWorker leaves the given worker, which has exited because it
+// failed too often, ran out of restarts or was paused, without
+// restarting it. A paused worker that hasn't reached its scheduled
+// start time yet keeps it, to be used when it's resumed.
+func (runner *Runner) parkWorker(id string, info *workerInfo) {
+	runner.mu.Lock()
+	info.worker = nil
+	if !info.scheduled {
+		info.nextStart = time.Time{}
+	}
+	runner.mu.Unlock()
+	go runner.awaitReset(info.newContext(id), id)
+}
+
+// awaitReset waits until the parked worker with the given id is
+// reset, resumed or stopped, or the runner is dying.
+func (runner *Runner) awaitReset(ctx context.Context, id string) {
+	select {
+	case <-runner.tomb.Dying():
+	case <-ctx.Done():
+	}
+	runner.sendDone(doneInfo{id: id})
+}
+
+// resetWorker responds when a worker is reset by calling
+// ResetWorker. It forgets the worker's failures and, if it has
+// failed or run out of restarts, interrupts awaitReset so that
+// workerDone starts it again.
+func (runner *Runner) resetWorker(id string) error {
+	info := runner.workers[id]
+	if info == nil {
+		return errors.NotFoundf("worker %q", id)
+	}
+	info.failures = nil
+	info.recentErrors = 0
+	if info.exhausted {
+		runner.mu.Lock()
+		info.restarts = 0
+		runner.mu.Unlock()
+	}
+	if !info.failed && !info.exhausted || info.stopping {
+		return nil
+	}
+	if info.paused {
+		// It will be started when it's resumed.
+		runner.mu.Lock()
+		info.failed = false
+		info.exhausted = false
+		runner.mu.Unlock()
+		return nil
+	}
+	info.restarting = true
+	info.cancel()
+	return nil
+}
+
+// pauseWorker responds when a worker is paused by calling
+// PauseWorker. It stops the current worker, if any, and
+// interrupts any pending restart or start; workerDone
+// will then park the worker until it is resumed.
+func (runner *Runner) pauseWorker(id string) error {
+	info := runner.workers[id]
+	if info == nil {
+		return errors.NotFoundf("worker %q", id)
+	}
+	if info.stopping {
+		return errors.Errorf("worker %q is stopping", id)
+	}
+	if info.paused {
+		return nil
+	}
+	if info.start == nil {
+		return errors.NotSupportedf("pausing added worker %q", id)
+	}
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+	info.paused = true
+	info.restarting = false
+	info.cancel()
+	if info.worker != nil {
+		runner.params.Logger.Debugf("killing %q for pause", id)
+		info.worker.Kill()
+		info.worker = nil
+	}
+	return nil
+}
+
+// resumeWorker responds when a worker is resumed by calling
+// ResumeWorker. The worker is started again as soon as it has
+// exited, or straight away if it has already been parked.
+func (runner *Runner) resumeWorker(id string) error {
+	info := runner.workers[id]
+	if info == nil {
+		return errors.NotFoundf("worker %q", id)
+	}
+	if !info.paused || info.stopping {
+		return nil
+	}
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+	info.paused = false
+	info.restarting = true
+	info.cancel()
+	return nil
+}
+
+// updateRecentErrors maintains the count of recent errors
+// used to calculate the restart backoff for a worker that
+// has just exited with the given error.
+func (runner *Runner) updateRecentErrors(info *workerInfo, err error) {
+	if err == nil {
+		info.recentErrors = 0
+		return
+	}
+	// If the worker never started, treat it the same way as
+	// a successful start followed by a quick failure.
+	timeSinceStarted := runner.params.Clock.Now().UTC().Sub(info.started)
+	if !info.running || timeSinceStarted < runner.params.BackoffResetTime {
+		info.recentErrors++
+	} else {
+		// It ran for long enough to reset the backoff.
+		info.recentErrors = 1
+	}
+}
+
+// recordExit records metrics for the given worker,
+// which has just exited with the given error.
+func (runner *Runner) recordExit(id string, info *workerInfo, err error, fatal bool) {
+	if info.running {
+		runner.params.Metrics.RecordUptime(id, runner.params.Clock.Now().UTC().Sub(info.started))
+	}
+	switch {
+	case err == nil:
+		runner.params.Metrics.RecordExit(id, ExitClean)
+	case fatal:
+		runner.params.Metrics.RecordExit(id, ExitFatal)
+	case !info.running:
+		runner.params.Metrics.RecordExit(id, ExitStartError)
+	default:
+		runner.params.Metrics.RecordExit(id, ExitError)
+	}
+}
+
+// restartDelay returns the length of time to wait before
+// restarting the given worker, taking into account any
+// exponential backoff.
+func (runner *Runner) restartDelay(info *workerInfo) time.Duration {
+	delay := info.restartDelay
+	if runner.params.BackoffFactor == 0 || delay <= 0 {
+		return delay
+	}
+	if info.recentErrors > 1 {
+		// Use the float64 values for max comparison. Otherwise when casting
+		// the float back to a duration we hit the int64 max which is negative.
+		maxDelay := float64(runner.params.MaxDelay)
+		floatDelay := float64(delay) * math.Pow(runner.params.BackoffFactor, float64(info.recentErrors-1))
+		if runner.params.MaxDelay > 0 && floatDelay > maxDelay {
+			delay = runner.params.MaxDelay
+		} else {
+			delay = time.Duration(floatDelay)
+		}
+	}
+	// Fuzz to ±10% of final duration.
+	fuzz := rand.Float64()*0.2 + 0.9
+	return time.Duration(float64(delay) * fuzz).Round(time.Millisecond)
+}
+
+// setFatal records that the worker with the given id
+// has exited with a fatal error, removes it and starts
+// to kill all the other workers.
+func (runner *Runner) setFatal(id string, err error) {
+	runner.params.Metrics.RecordFatal(id)
+	runner.publish(Event{Kind: EventFatal, ID: id, Err: err})
+	moreImportant := runner.params.MoreImportant
+	if info := runner.workers[id]; info.moreImportant != nil {
+		moreImportant = info.moreImportant
+	}
+	// The new error only replaces the current one if the worker
+	// that returned the current one doesn't rank it higher.
+	if runner.finalError == nil ||
+		moreImportant(err, runner.finalError) && !runner.finalErrorMoreImportant(runner.finalError, err) {
+		runner.finalError = err
+		runner.finalErrorMoreImportant = moreImportant
+	}
+	if runner.params.AggregateFatalErrors {
+		if runner.fatalErrors == nil {
+			runner.fatalErrors = make(map[string]error)
+		}
+		runner.fatalErrors[id] = err
+	}
+	runner.removeWorker(id, err)
+	if !runner.isDying {
+		runner.isDying = true
+		runner.killAll()
+	}
 }
 
 // removeWorker removes the worker with the given id from the
-// set of current workers. This should only be called when
+// set of current workers, reporting the error it finished with
+// to any StopAndRemoveWorker call. This should only be called when
 // the worker is not running.
-func (runner *Runner) removeWorker(id string, removed chan<- struct{}) {
+func (runner *Runner) removeWorker(id string, err error) {
 	runner.mu.Lock()
+	removed := runner.workers[id].done
 	delete(runner.workers, id)
-	removed <- struct{}{}
+	removed <- err
 	runner.mu.Unlock()
+	runner.publish(Event{Kind: EventRemoved, ID: id})
 }
 
 // setWorker sets the worker associated with the given id.
@@ -497,19 +2053,50 @@ func (runner *Runner) setWorker(id string, w Worker) {
 	defer runner.mu.Unlock()
 	info := runner.workers[id]
 	info.worker = w
+	info.started = runner.params.Clock.Now().UTC()
+	info.running = true
+	info.startCount++
+	info.nextStart = time.Time{}
+	info.scheduled = false
+	info.health = nil
+	info.healthChecked = false
+	info.healthFailures = 0
 	if runner.isDying || info.stopping {
-		// We're dying or the worker has already been
-		// stopped, so kill it already.
+		// The worker has already been stopped, or it
+		// wasn't running when the runner started dying,
+		// so kill it already.
 		runner.killWorkerLocked(id)
+	} else if info.restarting || info.paused {
+		// The worker was asked to restart or pause
+		// while it was starting, so kill it; workerDone
+		// will deal with it when it exits.
+		info.worker.Kill()
+		info.worker = nil
 	}
 }
 
-// killAll stops all the current workers.
+// killAll stops all the current workers in the earliest shutdown
+// stage that still has workers. As the runner is dying, it is called
+// again whenever a worker is removed, so that each stage is only
+// stopped when all the workers in earlier stages have finished.
+// Workers in any stage that aren't running are stopped straight
+// away, cancelling any pending start or restart, as no new workers
+// may be started once the runner is dying.
 func (runner *Runner) killAll() {
 	runner.mu.Lock()
 	defer runner.mu.Unlock()
-	for id := range runner.workers {
-		runner.killWorkerLocked(id)
+	first := true
+	stage := 0
+	for _, info := range runner.workers {
+		if first || info.stage < stage {
+			stage = info.stage
+			first = false
+		}
+	}
+	for id, info := range runner.workers {
+		if !info.stopping && (info.stage == stage || info.worker == nil) {
+			runner.killWorkerLocked(id)
+		}
 	}
 }
 
@@ -522,6 +2109,18 @@ func (runner *Runner) killWorker(id string) {
 	runner.killWorkerLocked(id)
 }
 
+// killWorkers stops all the workers whose labels
+// match the given selector.
+func (runner *Runner) killWorkers(selector Selector) {
+	runner.mu.Lock()
+	defer runner.mu.Unlock()
+	for id, info := range runner.workers {
+		if selector.Matches(info.labels) {
+			runner.killWorkerLocked(id)
+		}
+	}
+}
+
 // killWorkerLocked is like killWorker except that it expects
 // the runner.mu mutex to be held already.
 func (runner *Runner) killWorkerLocked(id string) {
@@ -529,8 +2128,14 @@ func (runner *Runner) killWorkerLocked(id string) {
 	if info == nil {
 		return
 	}
-	info.stopping = true
+	if !info.stopping {
+		info.stopping = true
+		info.stopRequested = runner.params.Clock.Now().UTC()
+	}
 	info.start = nil
+	// Interrupt any pending restart, and let
+	// any start function know it's been stopped.
+	info.cancel()
 	if info.worker != nil {
 		runner.params.Logger.Debugf("killing %q", id)
 		info.worker.Kill()
@@ -540,19 +2145,63 @@ func (runner *Runner) killWorkerLocked(id string) {
 	}
 }
 
-// runWorker starts the given worker after waiting for the given delay.
-func (runner *Runner) runWorker(delay time.Duration, id string, start func() (Worker, error)) {
+// runWorker starts the given worker after waiting for the given delay,
+// and claiming its lease if it has one. The delay is cut short, and
+// the worker not started, if the context is cancelled.
+func (runner *Runner) runWorker(ctx context.Context, delay time.Duration, id string, start func(context.Context) (Worker, error), lease Lease, grant chan struct{}) {
 	if delay > 0 {
-		runner.params.Logger.Infof("restarting %q in %v", id, delay)
-		// TODO(rog) provide a way of interrupting this
-		// so that it can be stopped when a worker is removed.
+		if attempt, _ := AttemptFromContext(ctx); attempt == 1 {
+			runner.params.Logger.Infof("starting %q in %v", id, delay)
+		} else {
+			runner.params.Logger.Infof("restarting %q in %v", id, delay)
+		}
 		select {
 		case <-runner.tomb.Dying():
-			runner.donec <- doneInfo{id, nil}
+			runner.sendDone(doneInfo{id: id})
+			return
+		case <-ctx.Done():
+			runner.params.Logger.Infof("restart of %q aborted", id)
+			runner.sendDone(doneInfo{id: id})
 			return
 		case <-runner.params.Clock.After(delay):
 		}
 	}
+	// attempted is set when the start function is called,
+	// and leaseLost is set if the worker's lease is lost.
+	attempted, leaseLost := false, false
+	done := func(err error) {
+		runner.sendDone(doneInfo{id: id, err: err, attempted: attempted, leaseLost: leaseLost})
+	}
+	// killed is closed if the worker is killed because
+	// its lease is lost.
+	var lost <-chan struct{}
+	killed := make(chan struct{})
+	if lease != nil {
+		runner.params.Logger.Debugf("claiming lease for %q", id)
+		var err error
+		if lost, err = lease.Claim(ctx); err != nil {
+			if ctx.Err() != nil {
+				runner.params.Logger.Infof("claim of lease for %q aborted", id)
+				err = nil
+			} else {
+				err = errors.Annotatef(err, "cannot claim lease for %q", id)
+			}
+			// Failing to claim the lease counts as
+			// failing to start the worker.
+			runner.sendDone(doneInfo{id: id, err: err, attempted: err != nil})
+			return
+		}
+		done = func(err error) {
+			if err := lease.Release(); err != nil {
+				runner.params.Logger.Errorf("cannot release lease for %q: %v", id, err)
+			}
+			runner.sendDone(doneInfo{id: id, err: err, attempted: attempted, leaseLost: leaseLost})
+		}
+	}
+	if runner.params.MaxConcurrentStarts > 0 && !runner.waitTurn(ctx, id, grant) {
+		done(nil)
+		return
+	}
 	runner.params.Logger.Infof("start %q", id)
 
 	// Defensively ensure that we get reasonable behaviour
@@ -567,27 +2216,172 @@ func (runner *Runner) runWorker(delay time.Duration, id string, start func() (Wo
 			return
 		}
 		// Since normal isn't true, it means that something
-		// inside start must have called panic or runtime.Goexit.
-		// If it's a panic, we let it panic; if it's called Goexit,
-		// we'll just return an error, enabling this functionality
-		// to be tested.
+		// inside start or Wait must have called panic or
+		// runtime.Goexit. If it's a panic, we let it panic unless
+		// we've been asked to recover from it; if it's called
+		// Goexit, we'll just return an error, enabling this
+		// functionality to be tested.
 		if err := recover(); err != nil {
-			panic(err)
+			if !runner.params.RecoverPanics {
+				panic(err)
+			}
+			done(newPanicError(err))
+			return
 		}
 		runner.params.Logger.Infof("%q called runtime.Goexit unexpectedly", id)
-		runner.donec <- doneInfo{id, errors.Errorf("runtime.Goexit called in running worker - probably inappropriate Assert")}
+		done(errors.Errorf("runtime.Goexit called in running worker - probably inappropriate Assert"))
 	}()
-	worker, err := start()
-	normal = true
-
+	attempted = true
+	worker, err := start(ctx)
 	if err == nil {
-		runner.startedc <- startInfo{id, worker}
+		select {
+		case runner.startedc <- startInfo{id, worker}:
+			if checker, ok := worker.(HealthChecker); ok && runner.params.HealthCheckInterval > 0 {
+				go runner.checkHealth(ctx, id, checker)
+			}
+			if lost != nil {
+				go runner.watchLease(ctx, id, worker, lost, killed)
+			}
+		case <-runner.tomb.Dead():
+			// The runner has abandoned its workers.
+			worker.Kill()
+		}
 		err = worker.Wait()
+		// Only blame the lost lease if we killed the worker
+		// for it and the worker had nothing else to say.
+		select {
+		case <-killed:
+			if err == nil {
+				err = ErrLeaseLost
+				leaseLost = true
+			}
+		default:
+		}
+	} else if ctx.Err() != nil {
+		// We've interrupted the start ourselves,
+		// so its error isn't a failure.
+		runner.params.Logger.Infof("start of %q aborted: %v", id, err)
+		err = nil
+	}
+	normal = true
+	runner.params.Logger.Infof("stopped %q, err: %v", id, err)
+	done(err)
+}
+
+// waitAdopted waits for the given worker, which was added
+// with AddWorker, to exit.
+// Its health isn't checked, as it can't be restarted if
+// it's unhealthy.
+func (runner *Runner) waitAdopted(id string, w Worker) {
+	done := func(err error) {
+		runner.sendDone(doneInfo{id: id, err: err, attempted: true})
 	}
+	// As in runWorker, make sure that the runner hears
+	// about the worker if its Wait panics or calls Goexit.
+	normal := false
+	defer func() {
+		if normal {
+			return
+		}
+		if err := recover(); err != nil {
+			if !runner.params.RecoverPanics {
+				panic(err)
+			}
+			done(newPanicError(err))
+			return
+		}
+		runner.params.Logger.Infof("%q called runtime.Goexit unexpectedly", id)
+		done(errors.Errorf("runtime.Goexit called in running worker - probably inappropriate Assert"))
+	}()
+	err := w.Wait()
+	normal = true
 	runner.params.Logger.Infof("stopped %q, err: %v", id, err)
-	runner.donec <- doneInfo{id, err}
+	done(err)
+}
+
+// watchLease kills the given worker if its lease is lost, closing
+// killed first, until the context is cancelled when it exits.
+func (runner *Runner) watchLease(ctx context.Context, id string, w Worker, lost <-chan struct{}, killed chan<- struct{}) {
+	select {
+	case <-lost:
+		runner.params.Logger.Infof("lease for %q lost, killing it", id)
+		close(killed)
+		w.Kill()
+	case <-ctx.Done():
+	}
+}
+
+// waitTurn waits until the run goroutine allows the worker with the
+// given id to start, and reports whether it may do so. It returns
+// false if the context is cancelled or the runner is dying first.
+// If grant is nil, the worker hasn't been queued yet, so it
+// queues itself first.
+func (runner *Runner) waitTurn(ctx context.Context, id string, grant chan struct{}) bool {
+	if grant == nil {
+		grant = make(chan struct{})
+		select {
+		case runner.queuec <- queueReq{id, grant}:
+		case <-ctx.Done():
+			return false
+		case <-runner.tomb.Dying():
+			return false
+		}
+	}
+	select {
+	case <-grant:
+		return true
+	case <-ctx.Done():
+	case <-runner.tomb.Dying():
+	}
+	runner.params.Logger.Infof("start of %q aborted while queued", id)
+	return false
+}
+
+// checkHealth checks the health of the worker with the given id
+// at intervals, until the context is cancelled when it exits.
+func (runner *Runner) checkHealth(ctx context.Context, id string, checker HealthChecker) {
+	attempt, _ := AttemptFromContext(ctx)
+	interval := runner.params.HealthCheckInterval
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-runner.params.Clock.After(interval):
+		}
+		checkCtx, cancel := context.WithCancel(ctx)
+		result := make(chan error, 1)
+		go func() {
+			result <- checker.CheckHealth(checkCtx)
+		}()
+		var err error
+		select {
+		case err = <-result:
+		case <-runner.params.Clock.After(interval):
+			err = errors.Errorf("health check timed out after %v", interval)
+		case <-ctx.Done():
+		}
+		cancel()
+		select {
+		case runner.healthc <- healthInfo{id, attempt, err}:
+		case <-ctx.Done():
+			return
+		}
+	}
 }
 
+// sendDone tells the run goroutine that the worker with
+// the given id has finished. If the runner has already
+// finished, having abandoned the worker, it does nothing.
+func (runner *Runner) sendDone(info doneInfo) {
+	select {
+	case runner.donec <- info:
+	case <-runner.tomb.Dead():
+	}
+}
+
+// reportTimeFormat holds the format used for times in reports.
+const reportTimeFormat = "2006-01-02 15:04:05"
+
 type reporter interface {
 	Report() map[string]interface{}
 }
@@ -595,15 +2389,58 @@ type reporter interface {
 // Report implements Reporter.
 func (runner *Runner) Report() map[string]interface{} {
 	workers := make(map[string]interface{})
+	now := runner.params.Clock.Now().UTC()
 	runner.mu.Lock()
 	defer runner.mu.Unlock()
 	for id, info := range runner.workers {
 		worker := info.worker
+		state := info.status(now)
 		workerReport := map[string]interface{}{
-			KeyState: info.status(),
+			KeyState:      state,
+			KeyStartCount: info.startCount,
 		}
 		if !info.started.IsZero() {
-			workerReport[KeyLastStart] = info.started.Format("2006-01-02 15:04:05")
+			workerReport[KeyLastStart] = info.started.Format(reportTimeFormat)
+		}
+		if info.stopping {
+			workerReport[KeyStoppingFor] = now.Sub(info.stopRequested).String()
+		}
+		if info.err != nil {
+			workerReport[KeyError] = info.err.Error()
+			workerReport[KeyErrorTime] = info.errTime.Format(reportTimeFormat)
+			if errWithStack, ok := info.err.(panicError); ok && errWithStack.Panicked() {
+				workerReport[KeyErrorStack] = strings.Join(errWithStack.StackTrace(), "\n")
+			}
+		}
+		if info.healthChecked {
+			if info.health == nil {
+				workerReport[KeyHealth] = "healthy"
+			} else {
+				workerReport[KeyHealth] = "unhealthy: " + info.health.Error()
+			}
+		}
+		if state == "stopped" || state == "scheduled" {
+			// The worker is waiting for its restart
+			// delay, or for its first start.
+			workerReport[KeyNextStart] = info.nextStart.Format(reportTimeFormat)
+		}
+		if info.lease != nil {
+			if info.running {
+				workerReport[KeyLease] = "held"
+			} else {
+				workerReport[KeyLease] = "not held"
+			}
+		}
+		if len(info.labels) > 0 {
+			labels := make(map[string]string, len(info.labels))
+			for k, v := range info.labels {
+				labels[k] = v
+			}
+			workerReport[KeyLabels] = labels
+		}
+		if max := info.policy.MaxRestarts; max > 0 {
+			workerReport[KeyRestartCount] = info.restarts
+			workerReport[KeyMaxRestarts] = max
 		}
 		if worker != nil {
 			if r, ok := worker.(reporter); ok {
@@ -4,6 +4,8 @@
 package worker_test
 
 import (
+	"context"
+	stderrors "errors"
 	"fmt"
 	"runtime"
 	"sync"
@@ -18,6 +20,7 @@ import (
 	"gopkg.in/tomb.v2"
 
 	"github.com/juju/worker/v3"
+	"github.com/juju/worker/v3/workertest"
 )
 
 type RunnerSuite struct {
@@ -157,17 +160,50 @@ func (*RunnerSuite) TestStopAndWaitWorkerReturnsWorkerError(c *gc.C) {
 }
 
 func (*RunnerSuite) TestStopAndWaitWorkerWithAbort(c *gc.C) {
-	t0 := time.Now()
-	clock := testclock.NewClock(t0)
 	runner := worker.NewRunner(worker.RunnerParams{
 		IsFatal:      noneFatal,
 		RestartDelay: time.Second,
+	})
+	defer worker.Stop(runner)
+	starter := newTestWorkerStarter()
+	starter.stopWait = make(chan struct{})
+	defer close(starter.stopWait)
+	runner.StartWorker("id", starter.start)
+	starter.assertStarted(c, true)
+
+	errc := make(chan error, 1)
+	stop := make(chan struct{})
+	go func() {
+		errc <- runner.StopAndRemoveWorker("id", stop)
+	}()
+	select {
+	case err := <-errc:
+		c.Fatalf("got unexpected result, error %q", err)
+	case <-time.After(shortWait):
+	}
+
+	close(stop)
+	select {
+	case err := <-errc:
+		c.Assert(errors.Cause(err), gc.Equals, worker.ErrAborted)
+	case <-time.After(longWait):
+		c.Fatalf("timed out waiting for worker")
+	}
+}
+
+func (*RunnerSuite) TestStopWorkerDuringRestartDelay(c *gc.C) {
+	clock := testclock.NewClock(time.Now())
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:      noneFatal,
+		RestartDelay: time.Hour,
 		Clock:        clock,
 	})
 	defer worker.Stop(runner)
 	starter := newTestWorkerStarter()
 	starter.startErr = errors.Errorf("test error")
-	runner.StartWorker("id", starter.start)
+	err := runner.StartWorker("id", starter.start)
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, false)
 
 	// Wait for the runner start waiting for the restart delay.
 	select {
@@ -176,134 +212,1725 @@ func (*RunnerSuite) TestStopAndWaitWorkerWithAbort(c *gc.C) {
 		c.Fatalf("runner never slept")
 	}
 
+	err = runner.StopWorker("id")
+	c.Assert(err, jc.ErrorIsNil)
+
+	// The worker is removed without waiting for the delay.
 	errc := make(chan error, 1)
-	stop := make(chan struct{})
 	go func() {
-		errc <- runner.StopAndRemoveWorker("id", stop)
+		_, err := runner.Worker("id", nil)
+		errc <- err
 	}()
 	select {
 	case err := <-errc:
-		c.Fatalf("got unexpected result, error %q", err)
-	case <-time.After(shortWait):
+		c.Assert(err, jc.Satisfies, errors.IsNotFound)
+	case <-time.After(longWait):
+		c.Fatalf("timed out waiting for worker to be removed")
+	}
+
+	// ...and it is never started again.
+	clock.Advance(time.Hour)
+	starter.assertNeverStarted(c, 0)
+}
+
+func (*RunnerSuite) TestStopAndRemoveWorkerDuringRestartDelay(c *gc.C) {
+	clock := testclock.NewClock(time.Now())
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:      noneFatal,
+		RestartDelay: time.Hour,
+		Clock:        clock,
+	})
+	defer worker.Stop(runner)
+	starter := newTestWorkerStarter()
+	err := runner.StartWorker("id", starter.start)
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, true)
+	starter.die <- errors.Errorf("test error")
+	starter.assertStarted(c, false)
+
+	// Wait for the runner start waiting for the restart delay.
+	select {
+	case <-clock.Alarms():
+	case <-time.After(longWait):
+		c.Fatalf("runner never slept")
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- runner.StopAndRemoveWorker("id", nil)
+	}()
+	select {
+	case err := <-errc:
+		c.Assert(err, jc.ErrorIsNil)
+	case <-time.After(longWait):
+		c.Fatalf("timed out waiting for worker to be removed")
+	}
+	_, err = runner.Worker("id", nil)
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+	starter.assertNeverStarted(c, 0)
+}
+
+func (*RunnerSuite) TestOneWorkerStartFatalError(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:      allFatal,
+		RestartDelay: time.Millisecond,
+	})
+	starter := newTestWorkerStarter()
+	starter.startErr = errors.New("cannot start test task")
+	err := runner.StartWorker("id", starter.start)
+	c.Assert(err, jc.ErrorIsNil)
+	err = runner.Wait()
+	c.Assert(err, gc.Equals, starter.startErr)
+}
+
+func (*RunnerSuite) TestOneWorkerDieFatalError(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:      allFatal,
+		RestartDelay: time.Millisecond,
+	})
+	starter := newTestWorkerStarter()
+	err := runner.StartWorker("id", starter.start)
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, true)
+	dieErr := errors.New("error when running")
+	starter.die <- dieErr
+	err = runner.Wait()
+	c.Assert(err, gc.Equals, dieErr)
+	starter.assertStarted(c, false)
+}
+
+func (*RunnerSuite) TestOneWorkerStartStop(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:      allFatal,
+		RestartDelay: time.Millisecond,
+	})
+	starter := newTestWorkerStarter()
+	err := runner.StartWorker("id", starter.start)
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, true)
+	err = runner.StopWorker("id")
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, false)
+	c.Assert(worker.Stop(runner), gc.IsNil)
+}
+
+func (*RunnerSuite) TestOneWorkerStopFatalError(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:      allFatal,
+		RestartDelay: time.Millisecond,
+	})
+	starter := newTestWorkerStarter()
+	starter.stopErr = errors.New("stop error")
+	err := runner.StartWorker("id", starter.start)
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, true)
+	err = runner.StopWorker("id")
+	c.Assert(err, jc.ErrorIsNil)
+	err = runner.Wait()
+	c.Assert(err, gc.Equals, starter.stopErr)
+}
+
+func (*RunnerSuite) TestWorkerStartWhenRunningOrStopping(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:      allFatal,
+		RestartDelay: 3 * time.Second,
+	})
+	starter := newTestWorkerStarter()
+	starter.stopWait = make(chan struct{})
+
+	// Start a worker, and wait for it.
+	err := runner.StartWorker("id", starter.start)
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, true)
+
+	err = runner.StartWorker("id", starter.start)
+	c.Assert(err, jc.Satisfies, errors.IsAlreadyExists)
+
+	// XXX the above does not imply the *runner* knows it's started.
+	// voodoo sleep ahoy!
+	time.Sleep(shortWait)
+
+	// Stop the worker, which will block...
+	err = runner.StopWorker("id")
+	c.Assert(err, jc.ErrorIsNil)
+
+	// While it's still blocked, try to start another.
+	err = runner.StartWorker("id", starter.start)
+	c.Assert(err, jc.Satisfies, errors.IsAlreadyExists)
+}
+
+func (*RunnerSuite) TestOneWorkerRestartDelay(c *gc.C) {
+	const delay = 100 * time.Millisecond
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:      noneFatal,
+		RestartDelay: delay,
+	})
+	starter := newTestWorkerStarter()
+	err := runner.StartWorker("id", starter.start)
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, true)
+	starter.die <- fmt.Errorf("non-fatal error")
+	starter.assertStarted(c, false)
+	t0 := time.Now()
+	starter.assertStarted(c, true)
+	restartDuration := time.Since(t0)
+	if restartDuration < delay {
+		c.Fatalf("restart delay was not respected; got %v want %v", restartDuration, delay)
+	}
+	c.Assert(worker.Stop(runner), gc.IsNil)
+}
+
+func (*RunnerSuite) TestRestartPolicyNever(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:      noneFatal,
+		RestartDelay: time.Millisecond,
+	})
+	defer worker.Stop(runner)
+	starter := newTestWorkerStarter()
+	err := runner.StartWorker("id", starter.start, worker.WithRestartPolicy(worker.RestartPolicy{
+		Mode: worker.RestartNever,
+	}))
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, true)
+	starter.die <- fmt.Errorf("non-fatal error")
+	starter.assertStarted(c, false)
+	starter.assertNeverStarted(c, time.Millisecond)
+
+	// The worker has been removed, so it can be started again.
+	err = runner.StartWorker("id", starter.start)
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, true)
+}
+
+func (*RunnerSuite) TestRestartPolicyAlways(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:      noneFatal,
+		RestartDelay: time.Millisecond,
+	})
+	defer worker.Stop(runner)
+	starter := newTestWorkerStarter()
+	err := runner.StartWorker("id", starter.start, worker.WithRestartPolicy(worker.RestartPolicy{
+		Mode: worker.RestartAlways,
+	}))
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, true)
+	starter.die <- nil
+	starter.assertStarted(c, false)
+	starter.assertStarted(c, true)
+}
+
+func (*RunnerSuite) TestRestartPolicyCustomDelay(c *gc.C) {
+	const delay = 100 * time.Millisecond
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:      noneFatal,
+		RestartDelay: time.Millisecond,
+	})
+	defer worker.Stop(runner)
+	starter := newTestWorkerStarter()
+	err := runner.StartWorker("id", starter.start, worker.WithRestartPolicy(worker.RestartPolicy{
+		Delay: delay,
+	}))
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, true)
+	starter.die <- fmt.Errorf("non-fatal error")
+	starter.assertStarted(c, false)
+	t0 := time.Now()
+	starter.assertStarted(c, true)
+	if restartDuration := time.Since(t0); restartDuration < delay {
+		c.Fatalf("restart delay was not respected; got %v want %v", restartDuration, delay)
+	}
+}
+
+func (*RunnerSuite) TestRestartPolicyMaxRestarts(c *gc.C) {
+	started := make(chan worker.Worker, 1)
+	runner := worker.NewRunnerWithNotify(worker.RunnerParams{
+		IsFatal:      noneFatal,
+		RestartDelay: time.Millisecond,
+	}, started)
+	defer worker.Stop(runner)
+	starter := newTestWorkerStarter()
+	err := runner.StartWorker("id", starter.start, worker.WithRestartPolicy(worker.RestartPolicy{
+		MaxRestarts: 2,
+	}))
+	c.Assert(err, jc.ErrorIsNil)
+	for i := 0; i < 3; i++ {
+		starter.assertStarted(c, true)
+		select {
+		case <-started:
+		case <-time.After(longWait):
+			c.Fatalf("timed out waiting for worker to be started")
+		}
+		report := runner.Report()["workers"].(map[string]interface{})["id"].(map[string]interface{})
+		c.Check(report[worker.KeyRestartCount], gc.Equals, i)
+		c.Check(report[worker.KeyMaxRestarts], gc.Equals, 2)
+		starter.die <- fmt.Errorf("non-fatal error")
+		starter.assertStarted(c, false)
 	}
+	starter.assertNeverStarted(c, time.Millisecond)
+	waitWorkerReport(c, runner, "id", worker.KeyState, "exhausted")
+	report := runner.Report()["workers"].(map[string]interface{})["id"].(map[string]interface{})
+	c.Check(report[worker.KeyRestartCount], gc.Equals, 2)
+	c.Check(report[worker.KeyMaxRestarts], gc.Equals, 2)
+
+	// Resetting the worker starts it again with a new allowance.
+	err = runner.ResetWorker("id")
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, true)
+	waitWorkerReport(c, runner, "id", worker.KeyState, "started")
+	report = runner.Report()["workers"].(map[string]interface{})["id"].(map[string]interface{})
+	c.Check(report[worker.KeyRestartCount], gc.Equals, 0)
+}
+
+func (*RunnerSuite) TestRestartPolicyMaxRestartsFatal(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:      noneFatal,
+		RestartDelay: time.Millisecond,
+	})
+	starter := newTestWorkerStarter()
+	err := runner.StartWorker("id", starter.start, worker.WithRestartPolicy(worker.RestartPolicy{
+		MaxRestarts:       1,
+		FatalOnExhaustion: true,
+	}))
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, true)
+	starter.die <- fmt.Errorf("first error")
+	starter.assertStarted(c, false)
+	starter.assertStarted(c, true)
+	lastErr := fmt.Errorf("last error")
+	starter.die <- lastErr
+	starter.assertStarted(c, false)
+	c.Assert(runner.Wait(), gc.Equals, lastErr)
+}
+
+func (*RunnerSuite) TestRestartBackoff(c *gc.C) {
+	clock := testclock.NewClock(time.Now())
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:       noneFatal,
+		RestartDelay:  time.Second,
+		BackoffFactor: 2,
+		MaxDelay:      3 * time.Second,
+		Clock:         clock,
+	})
+	defer worker.Stop(runner)
+	starter := newTestWorkerStarter()
+	starter.startErr = errors.Errorf("test error")
+	err := runner.StartWorker("id", starter.start)
+	c.Assert(err, jc.ErrorIsNil)
+
+	for _, delay := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second} {
+		starter.assertStarted(c, false)
+		select {
+		case <-clock.Alarms():
+		case <-time.After(longWait):
+			c.Fatalf("runner never slept")
+		}
+		// The delay is fuzzed by ±10%.
+		clock.Advance(delay * 85 / 100)
+		starter.assertNeverStarted(c, 0)
+		clock.Advance(delay * 30 / 100)
+	}
+	starter.assertStarted(c, false)
+}
+
+func (*RunnerSuite) TestRestartBackoffReset(c *gc.C) {
+	clock := testclock.NewClock(time.Now())
+	started := make(chan worker.Worker, 1)
+	runner := worker.NewRunnerWithNotify(worker.RunnerParams{
+		IsFatal:          noneFatal,
+		RestartDelay:     time.Second,
+		BackoffFactor:    2,
+		BackoffResetTime: time.Minute,
+		Clock:            clock,
+	}, started)
+	defer worker.Stop(runner)
+	starter := newTestWorkerStarter()
+	err := runner.StartWorker("id", starter.start)
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, true)
+	<-started
+
+	// Fail quickly twice so that the delay backs off.
+	for _, delay := range []time.Duration{time.Second, 2 * time.Second} {
+		starter.die <- errors.Errorf("test error")
+		starter.assertStarted(c, false)
+		select {
+		case <-clock.Alarms():
+		case <-time.After(longWait):
+			c.Fatalf("runner never slept")
+		}
+		clock.Advance(delay * 110 / 100)
+		starter.assertStarted(c, true)
+		<-started
+	}
+
+	// Stay up for long enough that the next failure is
+	// treated as a fresh one.
+	clock.Advance(2 * time.Minute)
+	starter.die <- errors.Errorf("test error")
+	starter.assertStarted(c, false)
+	select {
+	case <-clock.Alarms():
+	case <-time.After(longWait):
+		c.Fatalf("runner never slept")
+	}
+	clock.Advance(time.Second * 110 / 100)
+	starter.assertStarted(c, true)
+}
+
+func (*RunnerSuite) TestInvalidBackoffParams(c *gc.C) {
+	for i, test := range []struct {
+		params worker.RunnerParams
+		err    string
+	}{{
+		params: worker.RunnerParams{BackoffFactor: 0.5},
+		err:    "BackoffFactor 0.5 less than 1 not valid",
+	}, {
+		params: worker.RunnerParams{BackoffResetTime: -time.Second},
+		err:    "negative BackoffResetTime not valid",
+	}, {
+		params: worker.RunnerParams{MaxDelay: -time.Second},
+		err:    "negative MaxDelay not valid",
+	}} {
+		c.Logf("test %d", i)
+		c.Check(test.params.Validate(), gc.ErrorMatches, test.err)
+		runner := worker.NewRunner(test.params)
+		err := runner.Wait()
+		c.Check(err, gc.ErrorMatches, test.err)
+		c.Check(err, jc.Satisfies, errors.IsNotValid)
+		c.Check(runner.StartWorker("id", newTestWorkerStarter().start), gc.Equals, worker.ErrDead)
+	}
+}
+
+func (*RunnerSuite) TestShutdownStages(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:      allFatal,
+		RestartDelay: time.Millisecond,
+	})
+	consumer := newTestWorkerStarter()
+	consumer.stopWait = make(chan struct{})
+	err := runner.StartWorker("consumer", consumer.start)
+	c.Assert(err, jc.ErrorIsNil)
+	conn := newTestWorkerStarter()
+	err = runner.StartWorker("conn", conn.start, worker.WithShutdownStage(1))
+	c.Assert(err, jc.ErrorIsNil)
+	consumer.assertStarted(c, true)
+	conn.assertStarted(c, true)
+	// Make sure that the runner knows the connection is running,
+	// as workers that aren't running are stopped straight away.
+	_, err = runner.Worker("conn", nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	runner.Kill()
+
+	// The connection isn't stopped while the consumer is still stopping.
+	conn.assertNeverStarted(c, 0)
+
+	consumer.stopWait <- struct{}{}
+	consumer.assertStarted(c, false)
+	conn.assertStarted(c, false)
+	c.Assert(runner.Wait(), jc.ErrorIsNil)
+}
+
+func (*RunnerSuite) TestShutdownStagesCancelsPendingRestarts(c *gc.C) {
+	clock := testclock.NewClock(time.Now())
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal: func(err error) bool {
+			return err.Error() == "fatal"
+		},
+		RestartDelay: time.Hour,
+		Clock:        clock,
+	})
+	sub := runner.Subscribe(20)
+	defer sub.Unsubscribe()
+	a := newTestWorkerStarter()
+	a.stopWait = make(chan struct{})
+	err := runner.StartWorker("a", a.start)
+	c.Assert(err, jc.ErrorIsNil)
+	a.assertStarted(c, true)
+	b := newTestWorkerStarter()
+	err = runner.StartWorker("b", b.start, worker.WithShutdownStage(1))
+	c.Assert(err, jc.ErrorIsNil)
+	b.assertStarted(c, true)
+	cw := newTestWorkerStarter()
+	err = runner.StartWorker("c", cw.start)
+	c.Assert(err, jc.ErrorIsNil)
+	cw.assertStarted(c, true)
+
+	// The later stage worker is waiting to be restarted
+	// when the fatal error starts the shutdown.
+	b.die <- errors.New("boom")
+	b.assertStarted(c, false)
+	select {
+	case <-clock.Alarms():
+	case <-time.After(longWait):
+		c.Fatalf("runner never slept")
+	}
+	cw.die <- errors.New("fatal")
+	for removed := false; !removed; {
+		select {
+		case event := <-sub.Events():
+			removed = event.Kind == worker.EventRemoved && event.ID == "b"
+		case <-time.After(longWait):
+			c.Fatalf("timed out waiting for %q to be removed", "b")
+		}
+	}
+	clock.Advance(time.Hour)
+	b.assertNeverStarted(c, 0)
+
+	a.stopWait <- struct{}{}
+	c.Assert(runner.Wait(), gc.ErrorMatches, "fatal")
+	b.assertNeverStarted(c, 0)
+}
+
+func (*RunnerSuite) TestShutdownStagesCancelsStartContext(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:      allFatal,
+		RestartDelay: time.Millisecond,
+	})
+	a := newTestWorkerStarter()
+	a.stopWait = make(chan struct{})
+	err := runner.StartWorker("a", a.start)
+	c.Assert(err, jc.ErrorIsNil)
+	a.assertStarted(c, true)
+	starting := make(chan struct{})
+	cancelled := make(chan struct{})
+	err = runner.StartWorkerContext("b", func(ctx context.Context) (worker.Worker, error) {
+		close(starting)
+		<-ctx.Done()
+		close(cancelled)
+		return nil, ctx.Err()
+	}, worker.WithShutdownStage(1))
+	c.Assert(err, jc.ErrorIsNil)
+	select {
+	case <-starting:
+	case <-time.After(longWait):
+		c.Fatalf("timed out waiting for worker to start")
+	}
+
+	// The start is cancelled even though the
+	// earlier stage is still stopping.
+	runner.Kill()
+	select {
+	case <-cancelled:
+	case <-time.After(longWait):
+		c.Fatalf("start context never cancelled")
+	}
+	a.stopWait <- struct{}{}
+	c.Assert(runner.Wait(), jc.ErrorIsNil)
+}
+
+func (*RunnerSuite) TestStopTimeoutLogsStuckWorkers(c *gc.C) {
+	clock := testclock.NewClock(time.Now())
+	logger := &recordingLogger{}
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:      allFatal,
+		RestartDelay: time.Millisecond,
+		StopTimeout:  time.Minute,
+		Clock:        clock,
+		Logger:       logger,
+	})
+	stuck := workertest.NewForeverWorker(nil)
+	err := runner.StartWorker("stuck", func() (worker.Worker, error) {
+		return stuck, nil
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = runner.Worker("stuck", nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	runner.Kill()
+	select {
+	case <-clock.Alarms():
+	case <-time.After(longWait):
+		c.Fatalf("runner never started stop timeout")
+	}
+	clock.Advance(time.Minute)
+	// Wait for the timeout to be re-armed, showing the
+	// first one has been handled.
+	select {
+	case <-clock.Alarms():
+	case <-time.After(longWait):
+		c.Fatalf("runner never restarted stop timeout")
+	}
+	c.Assert(logger.errors(), jc.DeepEquals, []string{`"stuck" still stopping after 1m0s`})
+	report := runner.Report()["workers"].(map[string]interface{})["stuck"].(map[string]interface{})
+	c.Assert(report[worker.KeyState], gc.Equals, "stopping")
+	c.Assert(report[worker.KeyStoppingFor], gc.Equals, "1m0s")
+
+	stuck.ReallyKill()
+	c.Assert(runner.Wait(), jc.ErrorIsNil)
+}
+
+func (*RunnerSuite) TestStopTimeoutAbandonsStuckWorkers(c *gc.C) {
+	clock := testclock.NewClock(time.Now())
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:             allFatal,
+		RestartDelay:        time.Millisecond,
+		StopTimeout:         time.Minute,
+		AbandonStuckWorkers: true,
+		Clock:               clock,
+	})
+	stuck := workertest.NewForeverWorker(nil)
+	defer stuck.ReallyKill()
+	err := runner.StartWorker("stuck", func() (worker.Worker, error) {
+		return stuck, nil
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	later := newTestWorkerStarter()
+	err = runner.StartWorker("later", later.start, worker.WithShutdownStage(1))
+	c.Assert(err, jc.ErrorIsNil)
+	later.assertStarted(c, true)
+	_, err = runner.Worker("stuck", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = runner.Worker("later", nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	runner.Kill()
+	select {
+	case <-clock.Alarms():
+	case <-time.After(longWait):
+		c.Fatalf("runner never started stop timeout")
+	}
+	clock.Advance(time.Minute)
+	err = runner.Wait()
+	c.Assert(err, gc.FitsTypeOf, &worker.StuckWorkersError{})
+	c.Assert(err.(*worker.StuckWorkersError).IDs, jc.DeepEquals, []string{"stuck"})
+	c.Assert(err, gc.ErrorMatches, `workers still stopping after 1m0s: stuck`)
+
+	// Workers in later stages are still stopped.
+	later.assertStarted(c, false)
+}
+
+func (*RunnerSuite) TestStartWorkerContextCancelledOnStop(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:      noneFatal,
+		RestartDelay: time.Millisecond,
+	})
+	defer worker.Stop(runner)
+	starting := make(chan struct{})
+	cancelled := make(chan error, 1)
+	err := runner.StartWorkerContext("id", func(ctx context.Context) (worker.Worker, error) {
+		close(starting)
+		<-ctx.Done()
+		cancelled <- ctx.Err()
+		return nil, ctx.Err()
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	select {
+	case <-starting:
+	case <-time.After(longWait):
+		c.Fatalf("timed out waiting for worker to start")
+	}
+
+	err = runner.StopWorker("id")
+	c.Assert(err, jc.ErrorIsNil)
+	select {
+	case err := <-cancelled:
+		c.Assert(err, gc.Equals, context.Canceled)
+	case <-time.After(longWait):
+		c.Fatalf("timed out waiting for context to be cancelled")
+	}
+	_, err = runner.Worker("id", nil)
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+}
+
+func (*RunnerSuite) TestStartWorkerContextCancelledOnKill(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:      noneFatal,
+		RestartDelay: time.Millisecond,
+	})
+	starting := make(chan struct{})
+	err := runner.StartWorkerContext("id", func(ctx context.Context) (worker.Worker, error) {
+		close(starting)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	select {
+	case <-starting:
+	case <-time.After(longWait):
+		c.Fatalf("timed out waiting for worker to start")
+	}
+	c.Assert(worker.Stop(runner), jc.ErrorIsNil)
+}
+
+func (*RunnerSuite) TestStartWorkerContextCancelledNotFatal(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{
+		RestartDelay: time.Millisecond,
+	})
+	starting := make(chan struct{}, 2)
+	start := func(ctx context.Context) (worker.Worker, error) {
+		starting <- struct{}{}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	for _, id := range []string{"id0", "id1"} {
+		err := runner.StartWorkerContext(id, start)
+		c.Assert(err, jc.ErrorIsNil)
+		select {
+		case <-starting:
+		case <-time.After(longWait):
+			c.Fatalf("timed out waiting for worker to start")
+		}
+	}
+
+	// Stopping one worker doesn't kill the runner, even
+	// though all errors are fatal.
+	err := runner.StopAndRemoveWorker("id0", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	workertest.CheckAlive(c, runner)
+	c.Assert(runner.Workers(nil), jc.DeepEquals, []string{"id1"})
+
+	runner.Kill()
+	c.Assert(runner.Wait(), jc.ErrorIsNil)
+}
+
+func (*RunnerSuite) TestStartWorkerContextValues(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:      noneFatal,
+		RestartDelay: time.Millisecond,
+	})
+	defer worker.Stop(runner)
+	type values struct {
+		id      string
+		attempt int
+	}
+	valuesc := make(chan values, 2)
+	starter := newTestWorkerStarter()
+	err := runner.StartWorkerContext("id", func(ctx context.Context) (worker.Worker, error) {
+		id, ok := worker.WorkerIDFromContext(ctx)
+		c.Check(ok, jc.IsTrue)
+		attempt, ok := worker.AttemptFromContext(ctx)
+		c.Check(ok, jc.IsTrue)
+		valuesc <- values{id, attempt}
+		if attempt == 1 {
+			return nil, errors.New("first attempt fails")
+		}
+		return starter.start()
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, true)
+	c.Assert(<-valuesc, gc.Equals, values{"id", 1})
+	c.Assert(<-valuesc, gc.Equals, values{"id", 2})
+
+	_, ok := worker.WorkerIDFromContext(context.Background())
+	c.Assert(ok, jc.IsFalse)
+}
+
+func (*RunnerSuite) TestRestartWorker(c *gc.C) {
+	started := make(chan worker.Worker, 1)
+	runner := worker.NewRunnerWithNotify(worker.RunnerParams{
+		IsFatal:      noneFatal,
+		RestartDelay: time.Hour,
+	}, started)
+	defer worker.Stop(runner)
+	starter := newTestWorkerStarter()
+	err := runner.StartWorker("id", starter.start, worker.WithRestartPolicy(worker.RestartPolicy{
+		Mode: worker.RestartNever,
+	}))
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, true)
+	w0 := <-started
+
+	err = runner.RestartWorker("id")
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, false)
+	starter.assertStarted(c, true)
+	w1 := <-started
+	c.Assert(w1 == w0, jc.IsFalse)
+
+	w, err := runner.Worker("id", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(w, gc.Equals, w1)
+}
+
+func (*RunnerSuite) TestRestartWorkerDuringRestartDelay(c *gc.C) {
+	clock := testclock.NewClock(time.Now())
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:      noneFatal,
+		RestartDelay: time.Hour,
+		Clock:        clock,
+	})
+	defer worker.Stop(runner)
+	starter := newTestWorkerStarter()
+	err := runner.StartWorker("id", starter.start)
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, true)
+	starter.die <- errors.New("boom")
+	starter.assertStarted(c, false)
+	select {
+	case <-clock.Alarms():
+	case <-time.After(longWait):
+		c.Fatalf("runner never slept")
+	}
+
+	// The worker is started without advancing the clock.
+	err = runner.RestartWorker("id")
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, true)
+}
+
+func (*RunnerSuite) TestRestartWorkerNotFound(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{})
+	err := runner.RestartWorker("id")
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+	c.Assert(worker.Stop(runner), jc.ErrorIsNil)
+	c.Assert(runner.RestartWorker("id"), gc.Equals, worker.ErrDead)
+}
+
+func (*RunnerSuite) TestReplaceWorker(c *gc.C) {
+	started := make(chan worker.Worker, 1)
+	runner := worker.NewRunnerWithNotify(worker.RunnerParams{
+		IsFatal:      noneFatal,
+		RestartDelay: time.Hour,
+	}, started)
+	defer worker.Stop(runner)
+	starter0 := newTestWorkerStarter()
+	err := runner.StartWorker("id", starter0.start)
+	c.Assert(err, jc.ErrorIsNil)
+	starter0.assertStarted(c, true)
+	w0 := <-started
+
+	starter1 := newTestWorkerStarter()
+	err = runner.ReplaceWorker("id", starter1.start)
+	c.Assert(err, jc.ErrorIsNil)
+
+	// The worker is never reported as missing while
+	// it's being replaced.
+	w, err := runner.Worker("id", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	starter0.assertStarted(c, false)
+	starter1.assertStarted(c, true)
+	w1 := <-started
+	c.Assert(w1 == w0, jc.IsFalse)
+	c.Assert(w, gc.Equals, w1)
+
+	// The replacement is restarted with its own start function.
+	starter1.die <- errors.New("boom")
+	starter1.assertStarted(c, false)
+	err = runner.RestartWorker("id")
+	c.Assert(err, jc.ErrorIsNil)
+	starter1.assertStarted(c, true)
+	starter0.assertNeverStarted(c, 0)
+}
+
+func (*RunnerSuite) TestReplaceWorkerNotFound(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{})
+	starter := newTestWorkerStarter()
+	err := runner.ReplaceWorker("id", starter.start)
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, true)
+	c.Assert(worker.Stop(runner), jc.ErrorIsNil)
+	starter.assertStarted(c, false)
+	c.Assert(runner.ReplaceWorker("id", starter.start), gc.Equals, worker.ErrDead)
+}
+
+func (*RunnerSuite) TestRecoverPanicsFatal(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:       allFatal,
+		RecoverPanics: true,
+	})
+	err := runner.StartWorker("id", func() (worker.Worker, error) {
+		panic("boom")
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	err = runner.Wait()
+	c.Assert(err, gc.ErrorMatches, "panic resulted in: boom")
+	panicked, ok := err.(interface{ Panicked() bool })
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(panicked.Panicked(), jc.IsTrue)
+}
+
+func (*RunnerSuite) TestRecoverPanicsInWait(c *gc.C) {
+	clock := testclock.NewClock(time.Now())
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:       noneFatal,
+		RestartDelay:  time.Minute,
+		Clock:         clock,
+		RecoverPanics: true,
+	})
+	defer worker.Stop(runner)
+	starter := newTestWorkerStarter()
+	err := runner.StartWorker("id", func() (worker.Worker, error) {
+		return panicWorker{}, nil
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	select {
+	case <-clock.Alarms():
+	case <-time.After(longWait):
+		c.Fatalf("runner never slept")
+	}
+	report := runner.Report()["workers"].(map[string]interface{})["id"].(map[string]interface{})
+	c.Check(report[worker.KeyState], gc.Equals, "stopped")
+	c.Check(report[worker.KeyError], gc.Equals, "panic resulted in: waiting")
+	c.Check(report[worker.KeyErrorStack], gc.Matches, "(?s)goroutine.*panicWorker.*")
+
+	// The worker is restarted as usual.
+	err = runner.ReplaceWorker("id", starter.start)
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, true)
+}
+
+func (*RunnerSuite) TestFailureThreshold(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:          noneFatal,
+		RestartDelay:     time.Millisecond,
+		FailureThreshold: 3,
+		FailureWindow:    time.Minute,
+	})
+	defer worker.Stop(runner)
+	starter := newTestWorkerStarter()
+	err := runner.StartWorker("id", starter.start)
+	c.Assert(err, jc.ErrorIsNil)
+	for i := 0; i < 3; i++ {
+		starter.assertStarted(c, true)
+		starter.die <- errors.New("boom")
+		starter.assertStarted(c, false)
+	}
+	starter.assertNeverStarted(c, time.Millisecond)
+	report := runner.Report()["workers"].(map[string]interface{})["id"].(map[string]interface{})
+	c.Check(report[worker.KeyState], gc.Equals, "failed")
+	c.Check(report[worker.KeyError], gc.Equals, "boom")
+
+	err = runner.ResetWorker("id")
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, true)
+
+	// The failures were forgotten, so the worker is
+	// restarted after failing again.
+	starter.die <- errors.New("boom")
+	starter.assertStarted(c, false)
+	starter.assertStarted(c, true)
+}
+
+func (*RunnerSuite) TestFailureWindow(c *gc.C) {
+	clock := testclock.NewClock(time.Now())
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:          noneFatal,
+		RestartDelay:     time.Second,
+		Clock:            clock,
+		FailureThreshold: 2,
+		FailureWindow:    time.Minute,
+	})
+	defer worker.Stop(runner)
+	starter := newTestWorkerStarter()
+	err := runner.StartWorker("id", starter.start)
+	c.Assert(err, jc.ErrorIsNil)
+	for i := 0; i < 3; i++ {
+		starter.assertStarted(c, true)
+		starter.die <- errors.New("boom")
+		starter.assertStarted(c, false)
+		// Each failure is outside the window of the previous one.
+		err := clock.WaitAdvance(time.Minute, longWait, 1)
+		c.Assert(err, jc.ErrorIsNil)
+	}
+	starter.assertStarted(c, true)
+}
+
+func (*RunnerSuite) TestStopFailedWorker(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:          noneFatal,
+		RestartDelay:     time.Millisecond,
+		FailureThreshold: 1,
+	})
+	defer worker.Stop(runner)
+	sub := runner.Subscribe(10)
+	defer sub.Unsubscribe()
+	starter := newTestWorkerStarter()
+	err := runner.StartWorker("id", starter.start)
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, true)
+	starter.die <- errors.New("boom")
+	for event := range sub.Events() {
+		if event.Kind == worker.EventFailed {
+			break
+		}
+	}
+
+	err = runner.StopAndRemoveWorker("id", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	err = runner.ResetWorker("id")
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+}
+
+func (*RunnerSuite) TestPauseWorker(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:      noneFatal,
+		RestartDelay: time.Millisecond,
+	})
+	defer worker.Stop(runner)
+	starter := newTestWorkerStarter()
+	err := runner.StartWorker("id", starter.start)
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, true)
+
+	err = runner.PauseWorker("id")
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, false)
+	starter.assertNeverStarted(c, time.Millisecond)
+	report := runner.Report()["workers"].(map[string]interface{})["id"].(map[string]interface{})
+	c.Check(report[worker.KeyState], gc.Equals, "paused")
+	c.Check(runner.RestartWorker("id"), gc.ErrorMatches, `worker "id" is paused`)
+
+	err = runner.ResumeWorker("id")
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, true)
+}
+
+func (*RunnerSuite) TestPauseWorkerDuringRestartDelay(c *gc.C) {
+	clock := testclock.NewClock(time.Now())
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:      noneFatal,
+		RestartDelay: time.Hour,
+		Clock:        clock,
+	})
+	defer worker.Stop(runner)
+	starter := newTestWorkerStarter()
+	err := runner.StartWorker("id", starter.start)
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, true)
+	starter.die <- errors.New("boom")
+	starter.assertStarted(c, false)
+	select {
+	case <-clock.Alarms():
+	case <-time.After(longWait):
+		c.Fatalf("runner never slept")
+	}
+
+	err = runner.PauseWorker("id")
+	c.Assert(err, jc.ErrorIsNil)
+	clock.Advance(time.Hour)
+	starter.assertNeverStarted(c, 0)
+
+	// A paused worker can be replaced, but isn't
+	// started until it's resumed.
+	starter1 := newTestWorkerStarter()
+	err = runner.ReplaceWorker("id", starter1.start)
+	c.Assert(err, jc.ErrorIsNil)
+	starter1.assertNeverStarted(c, 0)
+	err = runner.ResumeWorker("id")
+	c.Assert(err, jc.ErrorIsNil)
+	starter1.assertStarted(c, true)
+	starter.assertNeverStarted(c, 0)
+}
+
+func (*RunnerSuite) TestPauseWorkerNotFound(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{})
+	c.Assert(runner.PauseWorker("id"), jc.Satisfies, errors.IsNotFound)
+	c.Assert(runner.ResumeWorker("id"), jc.Satisfies, errors.IsNotFound)
+	c.Assert(worker.Stop(runner), jc.ErrorIsNil)
+	c.Assert(runner.PauseWorker("id"), gc.Equals, worker.ErrDead)
+	c.Assert(runner.ResumeWorker("id"), gc.Equals, worker.ErrDead)
+}
+
+func (*RunnerSuite) TestMaxConcurrentStarts(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:             noneFatal,
+		MaxConcurrentStarts: 1,
+	})
+	defer worker.Stop(runner)
+	entered := make(chan string, 5)
+	release := make(chan struct{})
+	start := func(ctx context.Context) (worker.Worker, error) {
+		id, _ := worker.WorkerIDFromContext(ctx)
+		entered <- id
+		<-release
+		return workertest.NewErrorWorker(nil), nil
+	}
+	ids := []string{"a", "b", "c", "d", "e"}
+	for _, id := range ids {
+		err := runner.StartWorkerContext(id, start)
+		c.Assert(err, jc.ErrorIsNil)
+	}
+
+	// The workers are queued as soon as they're started.
+	workers := runner.Report()["workers"].(map[string]interface{})
+	for _, id := range ids[1:] {
+		c.Check(workers[id].(map[string]interface{})[worker.KeyState], gc.Equals, "queued")
+	}
+
+	// They start in order, one at a time.
+	select {
+	case got := <-entered:
+		c.Assert(got, gc.Equals, "a")
+	case <-time.After(longWait):
+		c.Fatalf("%q never started", "a")
+	}
+	for _, id := range ids[1:] {
+		select {
+		case id := <-entered:
+			c.Fatalf("%q started too early", id)
+		case <-time.After(shortWait):
+		}
+		release <- struct{}{}
+		select {
+		case got := <-entered:
+			c.Assert(got, gc.Equals, id)
+		case <-time.After(longWait):
+			c.Fatalf("%q never started", id)
+		}
+	}
+	release <- struct{}{}
+}
+
+func (*RunnerSuite) TestStopQueuedWorker(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:             noneFatal,
+		MaxConcurrentStarts: 1,
+	})
+	defer worker.Stop(runner)
+	release := make(chan struct{})
+	err := runner.StartWorker("a", func() (worker.Worker, error) {
+		<-release
+		return workertest.NewErrorWorker(nil), nil
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	starter := newTestWorkerStarter()
+	err = runner.StartWorker("b", starter.start)
+	c.Assert(err, jc.ErrorIsNil)
+	waitWorkerReport(c, runner, "b", worker.KeyState, "queued")
+
+	err = runner.StopAndRemoveWorker("b", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	close(release)
+	starter.assertNeverStarted(c, 0)
+}
+
+func (*RunnerSuite) TestHealthCheck(c *gc.C) {
+	clock := testclock.NewClock(time.Now())
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:             noneFatal,
+		Clock:               clock,
+		HealthCheckInterval: time.Minute,
+		HealthCheckFailures: 2,
+	})
+	defer worker.Stop(runner)
+	starter := newTestWorkerStarter()
+	health := make(chan error)
+	err := runner.StartWorker("id", func() (worker.Worker, error) {
+		w, err := starter.start()
+		return &healthWorker{Worker: w, health: health}, err
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, true)
+
+	// Wait for the first check, then for the next
+	// check along with the previous check's timeout.
+	err = clock.WaitAdvance(time.Minute, longWait, 1)
+	c.Assert(err, jc.ErrorIsNil)
+	health <- nil
+	waitWorkerReport(c, runner, "id", worker.KeyHealth, "healthy")
+
+	err = clock.WaitAdvance(time.Minute, longWait, 2)
+	c.Assert(err, jc.ErrorIsNil)
+	health <- errors.New("sick")
+	waitWorkerReport(c, runner, "id", worker.KeyHealth, "unhealthy: sick")
+	starter.assertNeverStarted(c, 0)
+
+	err = clock.WaitAdvance(time.Minute, longWait, 2)
+	c.Assert(err, jc.ErrorIsNil)
+	health <- errors.New("still sick")
+	starter.assertStarted(c, false)
+	starter.assertStarted(c, true)
+}
+
+func (*RunnerSuite) TestHealthCheckTimeout(c *gc.C) {
+	clock := testclock.NewClock(time.Now())
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:             noneFatal,
+		Clock:               clock,
+		HealthCheckInterval: time.Minute,
+	})
+	defer worker.Stop(runner)
+	starter := newTestWorkerStarter()
+	err := runner.StartWorker("id", func() (worker.Worker, error) {
+		w, err := starter.start()
+		return &healthWorker{Worker: w}, err
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, true)
+
+	err = clock.WaitAdvance(time.Minute, longWait, 1)
+	c.Assert(err, jc.ErrorIsNil)
+	err = clock.WaitAdvance(time.Minute, longWait, 1)
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, false)
+	starter.assertStarted(c, true)
+}
+
+func (*RunnerSuite) TestLabels(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal: noneFatal,
+	})
+	defer worker.Stop(runner)
+	starters := make(map[string]*testWorkerStarter)
+	for id, app := range map[string]string{
+		"uniter-foo/0": "foo",
+		"uniter-foo/1": "foo",
+		"uniter-bar/0": "bar",
+	} {
+		starter := newTestWorkerStarter()
+		starters[id] = starter
+		err := runner.StartWorker(id, starter.start, worker.WithLabels(map[string]string{
+			"kind": "uniter",
+			"app":  app,
+		}))
+		c.Assert(err, jc.ErrorIsNil)
+		starter.assertStarted(c, true)
+	}
+	c.Assert(runner.Workers(worker.Selector{"app": "foo"}), jc.DeepEquals, []string{"uniter-foo/0", "uniter-foo/1"})
+	c.Assert(runner.Workers(worker.Selector{"app": "baz"}), gc.HasLen, 0)
+	c.Assert(runner.Workers(nil), jc.DeepEquals, []string{"uniter-bar/0", "uniter-foo/0", "uniter-foo/1"})
+
+	report := runner.Report()["workers"].(map[string]interface{})["uniter-bar/0"].(map[string]interface{})
+	c.Assert(report[worker.KeyLabels], jc.DeepEquals, map[string]string{
+		"kind": "uniter",
+		"app":  "bar",
+	})
+
+	// An empty selector would stop everything, so it's refused.
+	err := runner.StopWorkers(nil)
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	err = runner.StopWorkers(worker.Selector{})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(runner.Workers(nil), gc.HasLen, 3)
+
+	err = runner.StopWorkers(worker.Selector{"kind": "uniter", "app": "foo"})
+	c.Assert(err, jc.ErrorIsNil)
+	starters["uniter-foo/0"].assertStarted(c, false)
+	starters["uniter-foo/1"].assertStarted(c, false)
+	starters["uniter-bar/0"].assertNeverStarted(c, 0)
+	w, err := runner.Worker("uniter-bar/0", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(w, gc.NotNil)
+}
+
+func (*RunnerSuite) TestSingletonWorker(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:      allFatal,
+		RestartDelay: time.Hour,
+	})
+	defer worker.Stop(runner)
+	lease := newTestLease()
+	starter := newTestWorkerStarter()
+	err := runner.StartWorker("id", starter.start, worker.WithLease(lease))
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertNeverStarted(c, 0)
+	waitWorkerReport(c, runner, "id", worker.KeyLease, "not held")
+
+	lost := make(chan struct{})
+	lease.grant <- lost
+	starter.assertStarted(c, true)
+	waitWorkerReport(c, runner, "id", worker.KeyLease, "held")
+
+	// Losing the lease kills the worker, which isn't fatal; it
+	// is started again, without the restart delay, as soon as
+	// the lease has been claimed again.
+	close(lost)
+	starter.assertStarted(c, false)
+	lease.assertReleased(c)
+	starter.assertNeverStarted(c, 0)
+	lease.grant <- nil
+	starter.assertStarted(c, true)
+
+	err = runner.StopAndRemoveWorker("id", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	lease.assertReleased(c)
+}
+
+func (*RunnerSuite) TestSingletonWorkerErrorAfterLeaseLost(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:      allFatal,
+		RestartDelay: time.Hour,
+	})
+	lease := newTestLease()
+	starter := newTestWorkerStarter()
+	starter.killErr = errors.New("fatal after lease lost")
+	err := runner.StartWorker("id", starter.start, worker.WithLease(lease))
+	c.Assert(err, jc.ErrorIsNil)
+	lost := make(chan struct{})
+	lease.grant <- lost
+	starter.assertStarted(c, true)
+
+	// The worker returns an error of its own when it's
+	// killed for losing its lease, so that error is
+	// checked like any other, rather than being hidden.
+	close(lost)
+	starter.assertStarted(c, false)
+	lease.assertReleased(c)
+	c.Assert(runner.Wait(), gc.ErrorMatches, "fatal after lease lost")
+	starter.assertNeverStarted(c, 0)
+}
+
+func (*RunnerSuite) TestWorkerReturnsErrLeaseLost(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal: allFatal,
+	})
+	starter := newTestWorkerStarter()
+	err := runner.StartWorker("id", starter.start, worker.WithRestartPolicy(worker.RestartPolicy{
+		Mode: worker.RestartNever,
+	}))
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, true)
+
+	// The worker didn't have a lease to lose, so
+	// its error is checked like any other.
+	starter.die <- worker.ErrLeaseLost
+	c.Assert(runner.Wait(), gc.Equals, worker.ErrLeaseLost)
+	starter.assertStarted(c, false)
+	starter.assertNeverStarted(c, 0)
+}
+
+func (*RunnerSuite) TestSetWorkers(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal: noneFatal,
+	})
+	defer worker.Stop(runner)
+	starters := make(map[string]*testWorkerStarter)
+	for _, id := range []string{"a", "b", "c"} {
+		starters[id] = newTestWorkerStarter()
+	}
+	starters["a"].stopWait = make(chan struct{})
+
+	result, err := runner.SetWorkers(map[string]worker.StartFunc{
+		"a": starters["a"].start,
+		"b": starters["b"].start,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(result, jc.DeepEquals, worker.SetWorkersResult{
+		Started: []string{"a", "b"},
+	})
+	starters["a"].assertStarted(c, true)
+	starters["b"].assertStarted(c, true)
+
+	result, err = runner.SetWorkers(map[string]worker.StartFunc{
+		"b": starters["b"].start,
+		"c": starters["c"].start,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(result, jc.DeepEquals, worker.SetWorkersResult{
+		Started:   []string{"c"},
+		Stopped:   []string{"a"},
+		Unchanged: []string{"b"},
+	})
+	starters["c"].assertStarted(c, true)
+	starters["b"].assertNeverStarted(c, 0)
+
+	// The worker that's still stopping can't be started again yet.
+	result, err = runner.SetWorkers(map[string]worker.StartFunc{
+		"a": starters["a"].start,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(result, jc.DeepEquals, worker.SetWorkersResult{
+		Stopped:  []string{"b", "c"},
+		Stopping: []string{"a"},
+	})
+	starters["a"].stopWait <- struct{}{}
+	starters["a"].assertStarted(c, false)
+	starters["b"].assertStarted(c, false)
+	starters["c"].assertStarted(c, false)
+}
+
+func (*RunnerSuite) TestSetWorkersWhenDead(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{})
+	c.Assert(worker.Stop(runner), jc.ErrorIsNil)
+	_, err := runner.SetWorkers(nil)
+	c.Assert(err, gc.Equals, worker.ErrDead)
+}
+
+func (*RunnerSuite) TestAddWorker(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:      noneFatal,
+		RestartDelay: time.Millisecond,
+	})
+	defer worker.Stop(runner)
+	sub := runner.Subscribe(10)
+	defer sub.Unsubscribe()
+	starter := newTestWorkerStarter()
+	w0, err := starter.start()
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, true)
+
+	err = runner.AddWorker("id", w0)
+	c.Assert(err, jc.ErrorIsNil)
+	w, err := runner.Worker("id", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(w, gc.Equals, w0)
+	c.Assert(runner.RestartWorker("id"), jc.Satisfies, errors.IsNotSupported)
+	c.Assert(runner.PauseWorker("id"), jc.Satisfies, errors.IsNotSupported)
+
+	// The worker isn't restarted when it fails.
+	starter.die <- errors.New("boom")
+	starter.assertStarted(c, false)
+	var kinds []worker.EventKind
+	for kind := worker.EventKind(""); kind != worker.EventRemoved; {
+		select {
+		case event := <-sub.Events():
+			kind = event.Kind
+			kinds = append(kinds, kind)
+		case <-time.After(longWait):
+			c.Fatalf("timed out waiting for worker to be removed")
+		}
+	}
+	c.Assert(kinds, jc.DeepEquals, []worker.EventKind{
+		worker.EventStarted,
+		worker.EventError,
+		worker.EventRemoved,
+	})
+}
+
+func (*RunnerSuite) TestAddWorkerFatal(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal: allFatal,
+	})
+	starter := newTestWorkerStarter()
+	w0, err := starter.start()
+	c.Assert(err, jc.ErrorIsNil)
+	err = runner.AddWorker("id", w0)
+	c.Assert(err, jc.ErrorIsNil)
+	starter.die <- errors.New("boom")
+	c.Assert(runner.Wait(), gc.ErrorMatches, "boom")
+}
+
+func (*RunnerSuite) TestAddWorkerNotHealthChecked(c *gc.C) {
+	clock := testclock.NewClock(time.Now())
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:             noneFatal,
+		Clock:               clock,
+		HealthCheckInterval: time.Minute,
+	})
+	defer worker.Stop(runner)
+	w := &healthWorker{
+		Worker: workertest.NewErrorWorker(nil),
+		health: make(chan error),
+	}
+	defer workertest.DirtyKill(c, w)
+	err := runner.AddWorker("id", w)
+	c.Assert(err, jc.ErrorIsNil)
+	select {
+	case <-clock.Alarms():
+		c.Fatalf("added worker's health checked")
+	case <-time.After(shortWait):
+	}
+	waitWorkerReport(c, runner, "id", worker.KeyState, "started")
+	report := runner.Report()["workers"].(map[string]interface{})["id"].(map[string]interface{})
+	_, ok := report[worker.KeyHealth]
+	c.Assert(ok, jc.IsFalse)
+}
+
+func (*RunnerSuite) TestAddWorkerWaitCallsGoexit(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal: allFatal,
+	})
+	defer worker.Stop(runner)
+	err := runner.AddWorker("id", goexitWorker{workertest.NewErrorWorker(nil)})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(runner.Wait(), gc.ErrorMatches, `runtime.Goexit called in running worker - probably inappropriate Assert`)
+}
+
+func (*RunnerSuite) TestAddWorkerAlreadyExists(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal: noneFatal,
+	})
+	defer worker.Stop(runner)
+	starter := newTestWorkerStarter()
+	err := runner.StartWorker("id", starter.start)
+	c.Assert(err, jc.ErrorIsNil)
+	w := workertest.NewErrorWorker(nil)
+	err = runner.AddWorker("id", w)
+	c.Assert(err, jc.Satisfies, errors.IsAlreadyExists)
+}
 
-	close(stop)
-	select {
-	case err := <-errc:
-		c.Assert(errors.Cause(err), gc.Equals, worker.ErrAborted)
-	case <-time.After(longWait):
-		c.Fatalf("timed out waiting for worker")
+func (*RunnerSuite) TestErrRestartNow(c *gc.C) {
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:      noneFatal,
+		RestartDelay: time.Hour,
+	})
+	defer worker.Stop(runner)
+	sub := runner.Subscribe(10)
+	defer sub.Unsubscribe()
+	starter := newTestWorkerStarter()
+	err := runner.StartWorker("id", starter.start, worker.WithRestartPolicy(worker.RestartPolicy{
+		Mode: worker.RestartNever,
+	}))
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, true)
+	starter.die <- errors.Annotate(worker.ErrRestartNow, "config changed")
+	starter.assertStarted(c, false)
+	starter.assertStarted(c, true)
+	for _, kind := range []worker.EventKind{
+		worker.EventStarted,
+		worker.EventRestartScheduled,
+		worker.EventStarted,
+	} {
+		select {
+		case event := <-sub.Events():
+			c.Assert(event.Kind, gc.Equals, kind)
+		case <-time.After(longWait):
+			c.Fatalf("timed out waiting for %s event", kind)
+		}
 	}
 }
 
-func (*RunnerSuite) TestOneWorkerStartFatalError(c *gc.C) {
+func (*RunnerSuite) TestRestartAfter(c *gc.C) {
+	clock := testclock.NewClock(time.Now())
 	runner := worker.NewRunner(worker.RunnerParams{
-		IsFatal:      allFatal,
-		RestartDelay: time.Millisecond,
+		IsFatal:      noneFatal,
+		RestartDelay: time.Hour,
+		Clock:        clock,
 	})
+	defer worker.Stop(runner)
 	starter := newTestWorkerStarter()
-	starter.startErr = errors.New("cannot start test task")
 	err := runner.StartWorker("id", starter.start)
 	c.Assert(err, jc.ErrorIsNil)
-	err = runner.Wait()
-	c.Assert(err, gc.Equals, starter.startErr)
+	starter.assertStarted(c, true)
+	starter.die <- worker.RestartAfter(time.Minute)
+	starter.assertStarted(c, false)
+	err = clock.WaitAdvance(time.Minute-time.Second, longWait, 1)
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertNeverStarted(c, shortWait)
+	clock.Advance(time.Second)
+	starter.assertStarted(c, true)
 }
 
-func (*RunnerSuite) TestOneWorkerDieFatalError(c *gc.C) {
+func (*RunnerSuite) TestErrRemove(c *gc.C) {
 	runner := worker.NewRunner(worker.RunnerParams{
-		IsFatal:      allFatal,
+		IsFatal:      noneFatal,
 		RestartDelay: time.Millisecond,
 	})
+	defer worker.Stop(runner)
+	sub := runner.Subscribe(10)
+	defer sub.Unsubscribe()
 	starter := newTestWorkerStarter()
-	err := runner.StartWorker("id", starter.start)
+	err := runner.StartWorker("id", starter.start, worker.WithRestartPolicy(worker.RestartPolicy{
+		Mode: worker.RestartAlways,
+	}))
 	c.Assert(err, jc.ErrorIsNil)
 	starter.assertStarted(c, true)
-	dieErr := errors.New("error when running")
-	starter.die <- dieErr
-	err = runner.Wait()
-	c.Assert(err, gc.Equals, dieErr)
+	starter.die <- worker.ErrRemove
 	starter.assertStarted(c, false)
+	starter.assertNeverStarted(c, time.Millisecond)
+	var kinds []worker.EventKind
+	for kind := worker.EventKind(""); kind != worker.EventRemoved; {
+		select {
+		case event := <-sub.Events():
+			kind = event.Kind
+			kinds = append(kinds, kind)
+		case <-time.After(longWait):
+			c.Fatalf("timed out waiting for worker to be removed")
+		}
+	}
+	c.Assert(kinds, jc.DeepEquals, []worker.EventKind{
+		worker.EventStarted,
+		worker.EventRemoved,
+	})
+	c.Assert(runner.Workers(nil), gc.HasLen, 0)
 }
 
-func (*RunnerSuite) TestOneWorkerStartStop(c *gc.C) {
+func (*RunnerSuite) TestRestartSentinelIsFatal(c *gc.C) {
 	runner := worker.NewRunner(worker.RunnerParams{
-		IsFatal:      allFatal,
-		RestartDelay: time.Millisecond,
+		IsFatal: allFatal,
 	})
 	starter := newTestWorkerStarter()
 	err := runner.StartWorker("id", starter.start)
 	c.Assert(err, jc.ErrorIsNil)
 	starter.assertStarted(c, true)
-	err = runner.StopWorker("id")
-	c.Assert(err, jc.ErrorIsNil)
+	starter.die <- worker.ErrRestartNow
+	c.Assert(runner.Wait(), gc.Equals, worker.ErrRestartNow)
 	starter.assertStarted(c, false)
-	c.Assert(worker.Stop(runner), gc.IsNil)
+	starter.assertNeverStarted(c, 0)
 }
 
-func (*RunnerSuite) TestOneWorkerStopFatalError(c *gc.C) {
+func (*RunnerSuite) TestWithIsFatal(c *gc.C) {
 	runner := worker.NewRunner(worker.RunnerParams{
 		IsFatal:      allFatal,
 		RestartDelay: time.Millisecond,
 	})
-	starter := newTestWorkerStarter()
-	starter.stopErr = errors.New("stop error")
-	err := runner.StartWorker("id", starter.start)
+	starter0 := newTestWorkerStarter()
+	err := runner.StartWorker("id0", starter0.start, worker.WithIsFatal(noneFatal))
 	c.Assert(err, jc.ErrorIsNil)
-	starter.assertStarted(c, true)
-	err = runner.StopWorker("id")
+	starter0.assertStarted(c, true)
+	starter1 := newTestWorkerStarter()
+	err = runner.StartWorker("id1", starter1.start)
 	c.Assert(err, jc.ErrorIsNil)
-	err = runner.Wait()
-	c.Assert(err, gc.Equals, starter.stopErr)
+	starter1.assertStarted(c, true)
+
+	// The first worker is restarted, as its error isn't fatal.
+	starter0.die <- errors.New("boom")
+	starter0.assertStarted(c, false)
+	starter0.assertStarted(c, true)
+	workertest.CheckAlive(c, runner)
+
+	starter1.die <- errors.New("bang")
+	c.Assert(runner.Wait(), gc.ErrorMatches, "bang")
 }
 
-func (*RunnerSuite) TestWorkerStartWhenRunningOrStopping(c *gc.C) {
+func (*RunnerSuite) TestWithFilter(c *gc.C) {
 	runner := worker.NewRunner(worker.RunnerParams{
 		IsFatal:      allFatal,
-		RestartDelay: 3 * time.Second,
+		RestartDelay: time.Hour,
 	})
+	defer worker.Stop(runner)
+	sub := runner.Subscribe(10)
+	defer sub.Unsubscribe()
+	errDone := errors.New("done")
+	filter := func(err error) error {
+		switch errors.Cause(err) {
+		case nil:
+			return worker.ErrRestartNow
+		case errDone:
+			return worker.ErrRemove
+		}
+		return err
+	}
 	starter := newTestWorkerStarter()
-	starter.stopWait = make(chan struct{})
-
-	// Start a worker, and wait for it.
-	err := runner.StartWorker("id", starter.start)
+	err := runner.StartWorker("id", starter.start,
+		worker.WithFilter(filter),
+		worker.WithIsFatal(func(err error) bool {
+			cause := errors.Cause(err)
+			return cause != worker.ErrRestartNow && cause != worker.ErrRemove
+		}),
+	)
 	c.Assert(err, jc.ErrorIsNil)
 	starter.assertStarted(c, true)
 
-	err = runner.StartWorker("id", starter.start)
-	c.Assert(err, jc.Satisfies, errors.IsAlreadyExists)
+	// A clean exit is converted into an immediate restart.
+	starter.die <- nil
+	starter.assertStarted(c, false)
+	starter.assertStarted(c, true)
 
-	// XXX the above does not imply the *runner* knows it's started.
-	// voodoo sleep ahoy!
-	time.Sleep(shortWait)
+	// The domain error is converted into removal.
+	starter.die <- errDone
+	starter.assertStarted(c, false)
+	var kinds []worker.EventKind
+	for kind := worker.EventKind(""); kind != worker.EventRemoved; {
+		select {
+		case event := <-sub.Events():
+			kind = event.Kind
+			kinds = append(kinds, kind)
+		case <-time.After(longWait):
+			c.Fatalf("timed out waiting for worker to be removed")
+		}
+	}
+	c.Assert(kinds, jc.DeepEquals, []worker.EventKind{
+		worker.EventStarted,
+		worker.EventRestartScheduled,
+		worker.EventStarted,
+		worker.EventRemoved,
+	})
+	workertest.CheckAlive(c, runner)
+}
 
-	// Stop the worker, which will block...
-	err = runner.StopWorker("id")
+func (*RunnerSuite) TestStartWorkerDelay(c *gc.C) {
+	t0 := time.Date(2022, 2, 3, 4, 5, 6, 0, time.UTC)
+	clock := testclock.NewClock(t0)
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal: noneFatal,
+		Clock:   clock,
+	})
+	defer worker.Stop(runner)
+	starter := newTestWorkerStarter()
+	err := runner.StartWorker("id", starter.start, worker.WithStartDelay(time.Minute))
 	c.Assert(err, jc.ErrorIsNil)
+	err = clock.WaitAdvance(time.Second, longWait, 1)
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertNeverStarted(c, 0)
+	c.Assert(runner.Report(), jc.DeepEquals, map[string]interface{}{
+		"workers": map[string]interface{}{
+			"id": map[string]interface{}{
+				"state":       "scheduled",
+				"start-count": 0,
+				"next-start":  "2022-02-03 04:06:06",
+			},
+		},
+	})
 
-	// While it's still blocked, try to start another.
-	err = runner.StartWorker("id", starter.start)
-	c.Assert(err, jc.Satisfies, errors.IsAlreadyExists)
+	clock.Advance(time.Minute - time.Second)
+	starter.assertStarted(c, true)
+	waitWorkerReport(c, runner, "id", worker.KeyState, "started")
 }
 
-func (*RunnerSuite) TestOneWorkerRestartDelay(c *gc.C) {
-	const delay = 100 * time.Millisecond
+func (*RunnerSuite) TestStartWorkerAt(c *gc.C) {
+	clock := testclock.NewClock(time.Now())
 	runner := worker.NewRunner(worker.RunnerParams{
-		IsFatal:      noneFatal,
-		RestartDelay: delay,
+		IsFatal: noneFatal,
+		Clock:   clock,
 	})
+	defer worker.Stop(runner)
 	starter := newTestWorkerStarter()
-	err := runner.StartWorker("id", starter.start)
+	err := runner.StartWorker("id", starter.start, worker.WithStartAt(clock.Now().Add(time.Hour)))
+	c.Assert(err, jc.ErrorIsNil)
+	err = clock.WaitAdvance(time.Hour, longWait, 1)
 	c.Assert(err, jc.ErrorIsNil)
 	starter.assertStarted(c, true)
-	starter.die <- fmt.Errorf("non-fatal error")
-	starter.assertStarted(c, false)
-	t0 := time.Now()
-	starter.assertStarted(c, true)
-	restartDuration := time.Since(t0)
-	if restartDuration < delay {
-		c.Fatalf("restart delay was not respected; got %v want %v", restartDuration, delay)
+
+	// A time in the past starts the worker straight away.
+	starter1 := newTestWorkerStarter()
+	err = runner.StartWorker("id1", starter1.start, worker.WithStartAt(clock.Now().Add(-time.Hour)))
+	c.Assert(err, jc.ErrorIsNil)
+	starter1.assertStarted(c, true)
+}
+
+func (*RunnerSuite) TestStopScheduledWorker(c *gc.C) {
+	clock := testclock.NewClock(time.Now())
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal: noneFatal,
+		Clock:   clock,
+	})
+	defer worker.Stop(runner)
+	starter := newTestWorkerStarter()
+	err := runner.StartWorker("id", starter.start, worker.WithStartDelay(time.Minute))
+	c.Assert(err, jc.ErrorIsNil)
+	select {
+	case <-clock.Alarms():
+	case <-time.After(longWait):
+		c.Fatalf("runner never scheduled worker")
 	}
-	c.Assert(worker.Stop(runner), gc.IsNil)
+	err = runner.StopAndRemoveWorker("id", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	clock.Advance(time.Minute)
+	starter.assertNeverStarted(c, 0)
+	c.Assert(runner.Workers(nil), gc.HasLen, 0)
+}
+
+func (*RunnerSuite) TestRestartScheduledWorker(c *gc.C) {
+	clock := testclock.NewClock(time.Now())
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal: noneFatal,
+		Clock:   clock,
+	})
+	defer worker.Stop(runner)
+	waitScheduled := func() {
+		select {
+		case <-clock.Alarms():
+		case <-time.After(longWait):
+			c.Fatalf("runner never scheduled worker")
+		}
+	}
+	starter := newTestWorkerStarter()
+	err := runner.StartWorker("id", starter.start, worker.WithStartDelay(time.Minute))
+	c.Assert(err, jc.ErrorIsNil)
+	waitScheduled()
+
+	// Restarting, pausing and resuming, or replacing the worker
+	// doesn't bring its scheduled start forward.
+	err = runner.RestartWorker("id")
+	c.Assert(err, jc.ErrorIsNil)
+	waitScheduled()
+	err = runner.PauseWorker("id")
+	c.Assert(err, jc.ErrorIsNil)
+	err = runner.ResumeWorker("id")
+	c.Assert(err, jc.ErrorIsNil)
+	waitScheduled()
+	replacement := newTestWorkerStarter()
+	err = runner.ReplaceWorker("id", replacement.start)
+	c.Assert(err, jc.ErrorIsNil)
+	waitScheduled()
+	replacement.assertNeverStarted(c, 0)
+	waitWorkerReport(c, runner, "id", worker.KeyState, "scheduled")
+
+	clock.Advance(time.Minute)
+	replacement.assertStarted(c, true)
+	starter.assertNeverStarted(c, 0)
 }
 
 type errorLevel int
@@ -334,6 +1961,74 @@ func (*RunnerSuite) TestErrorImportance(c *gc.C) {
 	c.Assert(err, gc.Equals, errorLevel(9))
 }
 
+func (*RunnerSuite) TestWithMoreImportant(c *gc.C) {
+	// The runner ranks higher levels as more important,
+	// but "b" ranks its own, lower level, above any other.
+	higher := func(err0, err1 error) bool {
+		return err0.(errorLevel) > err1.(errorLevel)
+	}
+	lower := func(err0, err1 error) bool {
+		return err0.(errorLevel) < err1.(errorLevel)
+	}
+	for _, first := range []string{"a", "b"} {
+		c.Logf("%q exits first", first)
+		runner := worker.NewRunner(worker.RunnerParams{
+			IsFatal:       allFatal,
+			MoreImportant: higher,
+			RestartDelay:  time.Millisecond,
+		})
+		levels := map[string]errorLevel{"a": 2, "b": 1}
+		for _, id := range []string{"a", "b"} {
+			starter := newTestWorkerStarter()
+			starter.stopErr = levels[id]
+			var options []worker.StartOption
+			if id == "b" {
+				options = append(options, worker.WithMoreImportant(lower))
+			}
+			err := runner.StartWorker(id, starter.start, options...)
+			c.Assert(err, jc.ErrorIsNil)
+			starter.assertStarted(c, true)
+		}
+
+		// Each worker's function ranks its own error higher,
+		// so whichever error came first is kept.
+		err := runner.StopWorker(first)
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(runner.Wait(), gc.Equals, levels[first])
+	}
+}
+
+func (*RunnerSuite) TestAggregateFatalErrors(c *gc.C) {
+	moreImportant := func(err0, err1 error) bool {
+		return err0.(errorLevel) > err1.(errorLevel)
+	}
+	id := func(i int) string { return fmt.Sprint(i) }
+	runner := worker.NewRunner(worker.RunnerParams{
+		IsFatal:              allFatal,
+		MoreImportant:        moreImportant,
+		AggregateFatalErrors: true,
+		RestartDelay:         time.Millisecond,
+	})
+	for i := 0; i < 3; i++ {
+		starter := newTestWorkerStarter()
+		starter.stopErr = errorLevel(i)
+		err := runner.StartWorker(id(i), starter.start)
+		c.Assert(err, jc.ErrorIsNil)
+	}
+	err := runner.StopWorker(id(1))
+	c.Assert(err, jc.ErrorIsNil)
+	err = runner.Wait()
+	c.Assert(err, gc.ErrorMatches, `worker "0": error with importance 0; `+
+		`worker "1": error with importance 1; worker "2": error with importance 2`)
+	c.Assert(err.(*worker.FatalErrors).Errors, jc.DeepEquals, map[string]error{
+		"0": errorLevel(0),
+		"1": errorLevel(1),
+		"2": errorLevel(2),
+	})
+	c.Assert(errors.Cause(err), gc.Equals, errorLevel(2))
+	c.Assert(stderrors.Is(err, errorLevel(2)), jc.IsTrue)
+}
+
 func (*RunnerSuite) TestStartWorkerWhenDead(c *gc.C) {
 	runner := worker.NewRunner(worker.RunnerParams{
 		IsFatal:      allFatal,
@@ -689,14 +2384,8 @@ func (*RunnerSuite) TestWorkerWhenWorkerRemovedWhileWaiting(c *gc.C) {
 	err := runner.StopWorker("id")
 	c.Assert(err, gc.Equals, nil)
 
-	// Wait until the runner gets around to restarting
-	// the worker.
-	// TODO(rog) this shouldn't be necessary - the Worker
-	// call should really fail immediately we remove the
-	// worker, but that requires some additional mechanism
-	// to interrupt the runWorker goroutine while it's sleeping,
-	// which doesn't exist yet.
-	clock.Advance(time.Second)
+	// The pending restart is interrupted, so the Worker
+	// call fails without the clock being advanced.
 	select {
 	case err := <-errc:
 		c.Assert(err, jc.Satisfies, errors.IsNotFound)
@@ -754,32 +2443,99 @@ func (*RunnerSuite) TestRunnerReport(c *gc.C) {
 			"worker-0": map[string]interface{}{
 				"report": map[string]interface{}{
 					"index": 0},
-				"state":   "started",
-				"started": "2018-08-07 13:15:42",
+				"state":       "started",
+				"started":     "2018-08-07 13:15:42",
+				"start-count": 1,
 			},
 			"worker-1": map[string]interface{}{
-				"state":   "started",
-				"started": "2018-08-07 13:15:42",
+				"state":       "started",
+				"started":     "2018-08-07 13:15:42",
+				"start-count": 1,
 			},
 			"worker-2": map[string]interface{}{
 				"report": map[string]interface{}{
 					"index": 2},
-				"state":   "started",
-				"started": "2018-08-07 13:15:42",
+				"state":       "started",
+				"started":     "2018-08-07 13:15:42",
+				"start-count": 1,
 			},
 			"worker-3": map[string]interface{}{
-				"state":   "started",
-				"started": "2018-08-07 13:15:42",
+				"state":       "started",
+				"started":     "2018-08-07 13:15:42",
+				"start-count": 1,
 			},
 			"worker-4": map[string]interface{}{
 				"report": map[string]interface{}{
 					"index": 4},
-				"state":   "started",
-				"started": "2018-08-07 13:15:42",
+				"state":       "started",
+				"started":     "2018-08-07 13:15:42",
+				"start-count": 1,
 			},
 		}})
 }
 
+func (*RunnerSuite) TestRunnerReportRestartDelay(c *gc.C) {
+	t0 := time.Date(2018, 8, 7, 19, 15, 42, 0, time.UTC)
+	started := make(chan worker.Worker, 1)
+	clock := testclock.NewClock(t0)
+	runner := worker.NewRunnerWithNotify(worker.RunnerParams{
+		IsFatal:      noneFatal,
+		RestartDelay: time.Minute,
+		Clock:        clock,
+	}, started)
+	defer worker.Stop(runner)
+
+	starter := newTestWorkerStarter()
+	err := runner.StartWorker("id", starter.start)
+	c.Assert(err, jc.ErrorIsNil)
+	starter.assertStarted(c, true)
+	select {
+	case <-started:
+	case <-time.After(longWait):
+		c.Fatalf("timed out waiting for worker to be started")
+	}
+	clock.Advance(time.Second)
+	starter.die <- errors.New("boom")
+	starter.assertStarted(c, false)
+	select {
+	case <-clock.Alarms():
+	case <-time.After(longWait):
+		c.Fatalf("runner never slept")
+	}
+
+	c.Assert(runner.Report(), jc.DeepEquals, map[string]interface{}{
+		"workers": map[string]interface{}{
+			"id": map[string]interface{}{
+				"state":       "stopped",
+				"started":     "2018-08-07 19:15:42",
+				"start-count": 1,
+				"error":       "boom",
+				"error-time":  "2018-08-07 19:15:43",
+				"next-start":  "2018-08-07 19:16:43",
+			},
+		},
+	})
+
+	clock.Advance(time.Minute)
+	starter.assertStarted(c, true)
+	select {
+	case <-started:
+	case <-time.After(longWait):
+		c.Fatalf("timed out waiting for worker to be restarted")
+	}
+	c.Assert(runner.Report(), jc.DeepEquals, map[string]interface{}{
+		"workers": map[string]interface{}{
+			"id": map[string]interface{}{
+				"state":       "started",
+				"started":     "2018-08-07 19:16:43",
+				"start-count": 2,
+				"error":       "boom",
+				"error-time":  "2018-08-07 19:15:43",
+			},
+		},
+	})
+}
+
 type testWorkerStarter struct {
 	startCount int32
 
@@ -928,3 +2684,107 @@ func allFatal(error) bool {
 func noImportance(err0, err1 error) bool {
 	return false
 }
+
+// recordingLogger records the messages logged at error level.
+type recordingLogger struct {
+	mu     sync.Mutex
+	errorf []string
+}
+
+func (l *recordingLogger) Debugf(string, ...interface{}) {}
+func (l *recordingLogger) Infof(string, ...interface{})  {}
+
+func (l *recordingLogger) Errorf(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.errorf = append(l.errorf, fmt.Sprintf(format, args...))
+}
+
+func (l *recordingLogger) errors() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.errorf...)
+}
+
+type panicWorker struct{}
+
+func (panicWorker) Kill() {}
+
+func (panicWorker) Wait() error {
+	panic("waiting")
+}
+
+// waitWorkerReport waits for the report of the worker
+// with the given id to hold the given value for key.
+func waitWorkerReport(c *gc.C, runner *worker.Runner, id, key string, value interface{}) {
+	timeout := time.After(longWait)
+	for {
+		workers := runner.Report()["workers"].(map[string]interface{})
+		if report, ok := workers[id].(map[string]interface{}); ok && report[key] == value {
+			return
+		}
+		select {
+		case <-timeout:
+			c.Fatalf("timed out waiting for %q to report %s %v", id, key, value)
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+type healthWorker struct {
+	worker.Worker
+	health chan error
+}
+
+func (w *healthWorker) CheckHealth(ctx context.Context) error {
+	select {
+	case err := <-w.health:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// goexitWorker is a worker whose Wait calls runtime.Goexit.
+type goexitWorker struct {
+	worker.Worker
+}
+
+func (goexitWorker) Wait() error {
+	runtime.Goexit()
+	panic("unreachable")
+}
+
+type testLease struct {
+	grant    chan chan struct{}
+	released chan struct{}
+}
+
+func newTestLease() *testLease {
+	return &testLease{
+		grant:    make(chan chan struct{}),
+		released: make(chan struct{}, 10),
+	}
+}
+
+func (l *testLease) Claim(ctx context.Context) (<-chan struct{}, error) {
+	select {
+	case lost := <-l.grant:
+		return lost, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *testLease) Release() error {
+	l.released <- struct{}{}
+	return nil
+}
+
+func (l *testLease) assertReleased(c *gc.C) {
+	select {
+	case <-l.released:
+	case <-time.After(longWait):
+		c.Fatalf("timed out waiting for lease to be released")
+	}
+}
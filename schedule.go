@@ -0,0 +1,229 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package worker
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+// Schedule determines when a PeriodicWorker runs its job.
+type Schedule interface {
+	// Next returns the first time after the given time that the
+	// job should run. It returns the zero time if the job should
+	// never run again.
+	Next(after time.Time) time.Time
+}
+
+// Every returns a Schedule that runs a job at the given interval,
+// which must be positive.
+func Every(interval time.Duration) (Schedule, error) {
+	return EveryWithJitter(interval, 0)
+}
+
+// EveryWithJitter returns a Schedule that runs a job at the given
+// interval, with each interval randomly lengthened or shortened by
+// up to the given fraction of it, so that jobs in many processes
+// don't run in lockstep. The interval must be positive, and the
+// jitter must be at least 0 and less than 1.
+func EveryWithJitter(interval time.Duration, jitter float64) (Schedule, error) {
+	if interval <= 0 {
+		return nil, errors.NotValidf("interval %v", interval)
+	}
+	if jitter < 0 || jitter >= 1 {
+		return nil, errors.NotValidf("jitter %v", jitter)
+	}
+	return intervalSchedule{interval: interval, jitter: jitter}, nil
+}
+
+type intervalSchedule struct {
+	interval time.Duration
+	jitter   float64
+}
+
+// Next implements Schedule.
+func (s intervalSchedule) Next(after time.Time) time.Time {
+	interval := float64(s.interval)
+	if s.jitter > 0 {
+		interval *= 1 + s.jitter*(rand.Float64()*2-1)
+	}
+	return after.Add(time.Duration(interval))
+}
+
+// cronDescriptors holds the cron expressions
+// equivalent to the predefined descriptors.
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronField describes one of the fields of a cron expression.
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// ParseCron returns a Schedule that runs a job at the times described
+// by the given cron expression. The expression has the usual five
+// fields: minute, hour, day of month, month and day of week, where
+// Sunday is 0 or 7. Each field may be "*", a value, a range such as
+// "1-5", a step such as "*/15" or "0-30/10", or a comma-separated
+// list of those. The descriptors @yearly, @annually, @monthly,
+// @weekly, @daily and @hourly are also accepted. As with cron, if
+// both the day of month and day of week are restricted, a job runs
+// on days that match either of them; a field starting with "*",
+// such as "*/2", doesn't count as restricted. Times are matched in the time
+// zone of the time passed to Next.
+func ParseCron(expr string) (Schedule, error) {
+	spec := strings.TrimSpace(expr)
+	if descriptor, ok := cronDescriptors[spec]; ok {
+		spec = descriptor
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return nil, errors.NotValidf("cron expression %q with %d fields", expr, len(fields))
+	}
+	var sets [5]uint64
+	for i, field := range fields {
+		set, err := parseCronField(field, cronFields[i])
+		if err != nil {
+			return nil, errors.Annotatef(err, "cron expression %q", expr)
+		}
+		sets[i] = set
+	}
+	// Sunday may be given as 7.
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+	return &cronSchedule{
+		minutes:     sets[0],
+		hours:       sets[1],
+		daysOfMonth: sets[2],
+		months:      sets[3],
+		daysOfWeek:  sets[4],
+		anyDay:      strings.HasPrefix(fields[2], "*") || strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+// parseCronField returns the set of values matched
+// by a field of a cron expression, as a bit mask.
+func parseCronField(field string, f cronField) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, errors.NotValidf("%s step %q", f.name, part[i+1:])
+			}
+			rangePart, step = part[:i], n
+		}
+		lo, hi := f.min, f.max
+		if rangePart != "*" {
+			var err error
+			if i := strings.Index(rangePart, "-"); i >= 0 {
+				lo, err = parseCronValue(rangePart[:i], f)
+				if err == nil {
+					hi, err = parseCronValue(rangePart[i+1:], f)
+				}
+			} else {
+				lo, err = parseCronValue(rangePart, f)
+				hi = lo
+				if step > 1 {
+					// A step from a single value runs to the end.
+					hi = f.max
+				}
+			}
+			if err != nil {
+				return 0, errors.Trace(err)
+			}
+			if lo > hi {
+				return 0, errors.NotValidf("%s range %q", f.name, rangePart)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+func parseCronValue(s string, f cronField) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, errors.NotValidf("%s %q", f.name, s)
+	}
+	return v, nil
+}
+
+// cronSchedule is a Schedule created from a cron expression.
+// Each field holds the set of matching values as a bit mask.
+type cronSchedule struct {
+	minutes     uint64
+	hours       uint64
+	daysOfMonth uint64
+	months      uint64
+	daysOfWeek  uint64
+
+	// anyDay holds whether either of the day fields started
+	// with "*", such as "*" or "*/2", in which case a day must
+	// match both of them.
+	anyDay bool
+}
+
+// cronSearchYears holds how far ahead a cronSchedule will look
+// for a matching time before deciding there isn't one.
+const cronSearchYears = 5
+
+// Next implements Schedule.
+func (s *cronSchedule) Next(after time.Time) time.Time {
+	loc := after.Location()
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(cronSearchYears, 0, 0)
+	for t.Before(limit) {
+		if s.months&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if s.hours&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if s.minutes&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s *cronSchedule) matchDay(t time.Time) bool {
+	dom := s.daysOfMonth&(1<<uint(t.Day())) != 0
+	dow := s.daysOfWeek&(1<<uint(t.Weekday())) != 0
+	if s.anyDay {
+		return dom && dow
+	}
+	return dom || dow
+}
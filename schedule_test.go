@@ -0,0 +1,155 @@
+// Copyright 2022 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package worker_test
+
+import (
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"github.com/juju/worker/v3"
+)
+
+type ScheduleSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&ScheduleSuite{})
+
+func (*ScheduleSuite) TestEvery(c *gc.C) {
+	t0 := time.Date(2022, 2, 3, 4, 5, 6, 0, time.UTC)
+	schedule, err := worker.Every(time.Minute)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(schedule.Next(t0), gc.Equals, t0.Add(time.Minute))
+}
+
+func (*ScheduleSuite) TestEveryWithJitter(c *gc.C) {
+	t0 := time.Date(2022, 2, 3, 4, 5, 6, 0, time.UTC)
+	schedule, err := worker.EveryWithJitter(time.Minute, 0.5)
+	c.Assert(err, jc.ErrorIsNil)
+	for i := 0; i < 100; i++ {
+		d := schedule.Next(t0).Sub(t0)
+		c.Assert(d >= 30*time.Second && d <= 90*time.Second, jc.IsTrue, gc.Commentf("%v", d))
+	}
+}
+
+func (*ScheduleSuite) TestEveryInvalid(c *gc.C) {
+	for i, test := range []struct {
+		interval time.Duration
+		jitter   float64
+		err      string
+	}{{
+		interval: 0,
+		err:      "interval 0s not valid",
+	}, {
+		interval: -time.Minute,
+		err:      "interval -1m0s not valid",
+	}, {
+		interval: time.Minute,
+		jitter:   -0.1,
+		err:      "jitter -0.1 not valid",
+	}, {
+		interval: time.Minute,
+		jitter:   1,
+		err:      "jitter 1 not valid",
+	}} {
+		c.Logf("test %d", i)
+		_, err := worker.EveryWithJitter(test.interval, test.jitter)
+		c.Check(err, gc.ErrorMatches, test.err)
+		c.Check(err, jc.Satisfies, errors.IsNotValid)
+	}
+	_, err := worker.Every(0)
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+}
+
+var cronTests = []struct {
+	expr  string
+	after string
+	next  string
+}{{
+	expr:  "* * * * *",
+	after: "2022-02-03 04:05:06",
+	next:  "2022-02-03 04:06:00",
+}, {
+	expr:  "*/15 * * * *",
+	after: "2022-02-03 04:05:06",
+	next:  "2022-02-03 04:15:00",
+}, {
+	expr:  "30 2 * * *",
+	after: "2022-02-03 04:05:06",
+	next:  "2022-02-04 02:30:00",
+}, {
+	expr:  "0 9-17/4 * * 1-5",
+	after: "2022-02-04 17:00:00", // Friday
+	next:  "2022-02-07 09:00:00",
+}, {
+	expr:  "0 0 1,15 * *",
+	after: "2022-02-03 04:05:06",
+	next:  "2022-02-15 00:00:00",
+}, {
+	// Either the day of month or the day of week matches.
+	expr:  "0 0 13 * 5",
+	after: "2022-02-03 04:05:06",
+	next:  "2022-02-04 00:00:00",
+}, {
+	// A step from "*" doesn't count as restricting the day,
+	// so this is odd days that are Mondays.
+	expr:  "0 0 */2 * 1",
+	after: "2022-02-07 00:00:00", // Monday
+	next:  "2022-02-21 00:00:00",
+}, {
+	expr:  "0 0 * * 7",
+	after: "2022-02-03 04:05:06",
+	next:  "2022-02-06 00:00:00",
+}, {
+	expr:  "@monthly",
+	after: "2022-12-03 04:05:06",
+	next:  "2023-01-01 00:00:00",
+}, {
+	expr:  "0 0 29 2 *",
+	after: "2022-02-03 04:05:06",
+	next:  "2024-02-29 00:00:00",
+}, {
+	expr:  "0 0 30 2 *",
+	after: "2022-02-03 04:05:06",
+	next:  "",
+}}
+
+func (*ScheduleSuite) TestParseCron(c *gc.C) {
+	const layout = "2006-01-02 15:04:05"
+	for i, test := range cronTests {
+		c.Logf("test %d: %s", i, test.expr)
+		schedule, err := worker.ParseCron(test.expr)
+		c.Assert(err, jc.ErrorIsNil)
+		after, err := time.Parse(layout, test.after)
+		c.Assert(err, jc.ErrorIsNil)
+		next := schedule.Next(after)
+		if test.next == "" {
+			c.Check(next.IsZero(), jc.IsTrue)
+			continue
+		}
+		c.Check(next.Format(layout), gc.Equals, test.next)
+	}
+}
+
+func (*ScheduleSuite) TestParseCronInvalid(c *gc.C) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"x * * * *",
+	} {
+		_, err := worker.ParseCron(expr)
+		c.Check(err, jc.Satisfies, errors.IsNotValid, gc.Commentf("%q", expr))
+	}
+}